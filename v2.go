@@ -2,215 +2,595 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
-	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"sync"
 	"syscall"
 	"time"
 )
 
-// Struktura przechowująca konfigurację monitora
+// Maksymalna liczba zapamiętanych restartów na program
+const maxRestartHistory = 100
+
+// Maksymalna liczba nowych bajtów logów czytana w jednym sprawdzeniu
+const maxLogReadBytes = 4 << 20
+
+// Stan nadzorowanego programu
+type programState int
+
+const (
+	stateStopped    programState = iota // Proces nie działa
+	stateStarting                       // Proces w fazie startu
+	stateRunning                        // Proces działa
+	stateRestarting                     // Trwa restart procesu
+	stateStopping                       // Trwa zatrzymywanie procesu
+	stateBackoff                        // Oczekiwanie przed ponownym uruchomieniem
+	stateCrashLoop                      // Pętla awarii - restarty wstrzymane
+	stateExited                         // Proces zakończył się, polityka nie wymaga restartu
+)
+
+func (s programState) String() string {
+	switch s {
+	case stateStarting:
+		return "uruchamianie"
+	case stateRunning:
+		return "działa"
+	case stateRestarting:
+		return "restartowanie"
+	case stateStopping:
+		return "zatrzymywanie"
+	case stateBackoff:
+		return "oczekiwanie na restart"
+	case stateCrashLoop:
+		return "pętla awarii"
+	case stateExited:
+		return "zakończony"
+	default:
+		return "zatrzymany"
+	}
+}
+
+// Pojedynczy wpis w historii restartów
+type restartRecord struct {
+	at     time.Time // Kiedy nastąpił restart
+	cause  string    // Przyczyna (stałe cause*)
+	reason string    // Powód restartu
+}
+
+// Struktura przechowująca konfigurację i stan monitora jednego programu
 type Monitor struct {
-	command     string        // Komenda do uruchomienia
-	logFile     string        // Ścieżka do pliku logów
-	timeout     time.Duration // Jak długo czekać bez zmian w logach
-	interval    time.Duration // Jak często sprawdzać
-	process     *exec.Cmd     // Wskaźnik do uruchomionego procesu
-	lastModTime time.Time     // Kiedy ostatnio zmieniły się logi
-	lastLogSize int64         // Ostatni rozmiar pliku logów
-	mutex       sync.RWMutex  // Mutex do synchronizacji dostępu do procesu
-	ctx         context.Context
-	cancel      context.CancelFunc
+	name            string              // Nazwa programu (prefiks komunikatów)
+	command         string              // Komenda powłoki lub opis args do komunikatów
+	args            []string            // Program i argumenty bez powłoki (nil = tryb powłoki)
+	shell           []string            // Interpreter komendy powłoki z argumentami
+	logFile         string              // Ścieżka do pliku logów
+	timeout         time.Duration       // Jak długo czekać bez zmian w logach
+	interval        time.Duration       // Jak często sprawdzać
+	killGrace       time.Duration       // Czas między SIGTERM a SIGKILL
+	stopCfg         StopConfig          // Sekwencja zatrzymania i akcja przed zatrzymaniem
+	hooks           HooksConfig         // Hooki cyklu życia
+	diagCfg         DiagnosticsConfig   // Diagnostyka przed zabiciem zawieszonego procesu
+	cgroupCfg       *CgroupConfig       // Limity zasobów w cgroup v2 (nil = bez cgroup)
+	cgroup          *cgroup             // Cgroup bieżącego procesu
+	watchdogCfg     WatchdogConfig      // Limity RSS, CPU, deskryptorów i wątków
+	watchdog        resourceWatchdog    // Stan strażnika zasobów (tylko pętla nadzoru)
+	usage           resourceUsage       // Ostatnia próbka zużycia zasobów
+	leakCfg         LeakConfig          // Wykrywanie wycieku pamięci
+	leak            leakTracker         // Próbki RSS do trendu (tylko pętla nadzoru)
+	leakPlan        leakPrediction      // Prognoza wycieku i zaplanowany restart
+	lastStop        stopResult          // Wynik ostatniego zatrzymania procesu
+	envFile         string              // Plik dotenv czytany przy każdym starcie
+	clearEnv        bool                // Proces nie dziedziczy środowiska monitora
+	workingDir      string              // Katalog roboczy procesu
+	umask           int                 // Umask procesu (-1 = dziedziczony)
+	runUser         string              // Użytkownik procesu (pusty = jak monitor)
+	runGroup        string              // Grupa procesu (pusta = główna grupa użytkownika)
+	newSession      bool                // Proces w nowej sesji (setsid) zamiast tylko grupy
+	process         *exec.Cmd           // Wskaźnik do uruchomionego procesu
+	exit            *processExit        // Oczekiwanie na zakończenie bieżącego procesu
+	lastExit        *processExit        // Wynik ostatniego zakończonego procesu
+	startErr        error               // Błąd ostatniej próby uruchomienia (nil po udanym starcie)
+	exits           chan *processExit   // Zdarzenia zakończenia procesów dla pętli nadzoru
+	lastModTime     time.Time           // Kiedy ostatnio zmieniły się logi
+	lastLogSize     int64               // Pozycja odczytu w bieżącym pliku logów
+	partialLine     string              // Niezakończona linia z poprzedniego odczytu
+	logHandle       *os.File            // Otwarty plik logów (przetrwa rotację przez rename)
+	logID           logIdentity         // Urządzenie i i-węzeł otwartego pliku logów
+	logModTime      time.Time           // Ostatnio widziany czas modyfikacji pliku logów
+	logMissing      bool                // Plik logów zniknął po rotacji
+	outputCfg       OutputConfig        // Ustawienia przechwytywania wyjścia procesu
+	output          *outputCapture      // Przechwytywanie wyjścia (nil gdy wyłączone)
+	streams         []*lineWriter       // Strumienie stdout/stderr bieżącego procesu
+	matcher         *logMatcher         // Wzorce heartbeatu, linii ignorowanych i błędów
+	errorReason     string              // Powód restartu wyzwolonego wzorcem błędu
+	probeCfgs       []ProbeConfig       // Konfiguracja sond liveness, readiness i startup
+	probes          []*probeRunner      // Sondy bieżącego procesu
+	probeStop       func()              // Zatrzymuje sondy bieżącego procesu
+	startupCfg      StartupConfig       // Faza startu programu
+	startupPattern  *regexp.Regexp      // Wzorzec linii kończącej fazę startu (nil gdy brak)
+	startupMatched  bool                // Czy w fazie startu pojawiła się linia ze wzorca
+	started         chan struct{}       // Zamykany po zakończeniu fazy startu
+	startupFailures int                 // Liczba nieudanych startów
+	state           programState        // Aktualny stan programu
+	startedAt       time.Time           // Kiedy uruchomiono bieżący proces
+	restarts        []restartRecord     // Historia restartów (najnowsze na końcu)
+	restartsByCause map[string]int      // Liczba wszystkich restartów według przyczyny
+	logBytes        int64               // Bajty logów zapisane przez proces
+	stops           int                 // Liczba zatrzymań procesu przez monitor
+	stopsKilled     int                 // Zatrzymania, które wymagały SIGKILL
+	restartCfg      RestartConfig       // Polityka restartów (backoff, pętla awarii)
+	failures        int                 // Restarty od ostatniego stabilnego działania
+	onCrashLoop     func(*Monitor)      // Wywoływana po wykryciu pętli awarii
+	events          *eventBus           // Szyna zdarzeń supervisora (nil gdy brak)
+	userStopped     bool                // Zatrzymany na żądanie - bez restartów
+	paused          bool                // Nadzór wstrzymany przez operatora
+	config          ProgramConfig       // Konfiguracja, z której utworzono monitor
+	requests        chan monitorRequest // Polecenia operatora dla pętli nadzoru
+	stopped         chan struct{}       // Zamykany po zakończeniu pętli nadzoru
+	mutex           sync.RWMutex        // Mutex do synchronizacji dostępu do procesu
 }
 
 // Konstruktor - tworzy nową instancję monitora
-func NewMonitor(command, logFile string, timeout, interval int) *Monitor {
-	ctx, cancel := context.WithCancel(context.Background())
-	return &Monitor{
-		command:  command,
-		logFile:  logFile,
-		timeout:  time.Duration(timeout) * time.Second,
-		interval: time.Duration(interval) * time.Second,
-		ctx:      ctx,
-		cancel:   cancel,
+func NewMonitor(cfg ProgramConfig) *Monitor {
+	// Wzorce zostały sprawdzone w validate(), więc błąd nie wystąpi
+	matcher, _ := newLogMatcher(cfg)
+	umask, _ := parseUmask(cfg.Umask)
+
+	m := &Monitor{
+		name:        cfg.Name,
+		command:     commandLine(cfg.Command, cfg.Args),
+		args:        cfg.Args,
+		shell:       shellArgv(cfg.Shell),
+		logFile:     cfg.LogFile,
+		timeout:     cfg.Timeout.Duration,
+		interval:    cfg.Interval.Duration,
+		killGrace:   cfg.KillGrace.Duration,
+		stopCfg:     cfg.Stop,
+		hooks:       cfg.Hooks,
+		diagCfg:     cfg.Diagnostics,
+		cgroupCfg:   cfg.Cgroup,
+		watchdogCfg: cfg.Watchdog,
+		leakCfg:     cfg.Leak,
+		envFile:     cfg.EnvFile,
+		clearEnv:    cfg.ClearEnv,
+		workingDir:  cfg.WorkingDir,
+		umask:       umask,
+		runUser:     cfg.User,
+		runGroup:    cfg.Group,
+		newSession:  cfg.NewSession,
+		matcher:     matcher,
+		outputCfg:   cfg.Output,
+		restartCfg:  cfg.Restart,
+		probeCfgs:   cfg.Probes,
+		startupCfg:  cfg.Startup,
+		exits:       make(chan *processExit, 8),
+		config:      cfg,
+		requests:    make(chan monitorRequest),
+		stopped:     make(chan struct{}),
+	}
+	m.restartsByCause = make(map[string]int)
+	if cfg.Startup.LogPattern != "" {
+		m.startupPattern = regexp.MustCompile(cfg.Startup.LogPattern)
 	}
+	return m
 }
 
-// Pobiera informacje o pliku logów (czas modyfikacji i rozmiar)
-func (m *Monitor) getLogInfo() (time.Time, int64, error) {
-	info, err := os.Stat(m.logFile)
-	if err != nil {
-		return time.Time{}, 0, err
+// Wypisuje komunikat poprzedzony nazwą programu
+func (m *Monitor) logf(format string, args ...interface{}) {
+	fmt.Fprintf(humanOutput, "[%s] "+format, append([]interface{}{m.name}, args...)...)
+}
+
+// Zapisuje restart w historii programu i oznacza go jako restartowany
+func (m *Monitor) recordRestart(cause, reason string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.state = stateRestarting
+	m.restarts = append(m.restarts, restartRecord{at: time.Now(), cause: cause, reason: reason})
+	m.restartsByCause[cause]++
+	if len(m.restarts) > maxRestartHistory {
+		m.restarts = m.restarts[len(m.restarts)-maxRestartHistory:]
+	}
+
+	m.emit(Event{Type: eventRestartRequested, PID: m.pidUnsafe(), Cause: cause, Reason: reason})
+}
+
+// PID bieżącego procesu lub 0 (wywoływana z zablokowanym mutexem)
+func (m *Monitor) pidUnsafe() int {
+	if m.process == nil || m.process.Process == nil {
+		return 0
 	}
-	return info.ModTime(), info.Size(), nil
+	return m.process.Process.Pid
+}
+
+// Zapisuje czas ostatniej aktywności w logach (odczytywany przez metryki)
+func (m *Monitor) setLastActivity(t time.Time) {
+	m.mutex.Lock()
+	m.lastModTime = t
+	m.mutex.Unlock()
+}
+
+// Zwraca aktualny stan programu
+func (m *Monitor) State() programState {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.state
+}
+
+// Zwraca liczbę restartów zapisanych w historii
+func (m *Monitor) RestartCount() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.restarts)
 }
 
 // Sprawdza czy w logach pojawiły się nowe wpisy
 func (m *Monitor) checkLogs() (bool, error) {
-	modTime, size, err := m.getLogInfo()
-	if err != nil {
-		return false, fmt.Errorf("nie można odczytać pliku logów: %v", err)
+	// Przy przechwytywaniu wyjścia aktywnością są linie ze strumienia procesu,
+	// w przeciwnym razie nowe wpisy odczytane z pliku
+	var read logRead
+	if m.output != nil {
+		read = m.output.take()
+	} else {
+		var err error
+		read, err = m.tailLog()
+		if err != nil {
+			return false, fmt.Errorf("nie można odczytać pliku logów: %v", err)
+		}
 	}
 
 	// Pierwsza iteracja - zapisz początkowy stan
 	if m.lastModTime.IsZero() {
-		m.lastModTime = modTime
-		m.lastLogSize = size
-		fmt.Printf("Początkowy stan logów: rozmiar %d bajtów\n", size)
+		m.setLastActivity(read.modTime)
+		m.logModTime = read.modTime
+		m.logf("Początkowy stan logów: rozmiar %d bajtów\n", read.size)
 		return true, nil
 	}
 
-	// Sprawdź czy plik urósł (nowe logi)
-	if size > m.lastLogSize {
-		fmt.Printf("Nowe logi: rozmiar %d -> %d bajtów (+%d)\n", 
-			m.lastLogSize, size, size-m.lastLogSize)
-		m.lastModTime = time.Now()
-		m.lastLogSize = size
+	// Sprawdź czy pojawiły się nowe wpisy - licznik resetuje tylko heartbeat
+	if read.bytes > 0 {
+		m.mutex.Lock()
+		m.logBytes += read.bytes
+		m.mutex.Unlock()
+
+		m.handleErrorPatterns(read.lines)
+		m.observeStartupLines(read.lines)
+
+		if heartbeats := m.matcher.countHeartbeats(read.lines); heartbeats > 0 || !m.matcher.contentAware() {
+			m.logf("Nowe logi: rozmiar %d bajtów (+%d)\n", read.size, read.bytes)
+			m.setLastActivity(time.Now())
+			if !read.modTime.IsZero() {
+				m.logModTime = read.modTime
+			}
+			return true, nil
+		}
+		m.logf("Nowe logi bez heartbeatu: %d linii (+%d bajtów), licznik nie jest resetowany\n",
+			len(read.lines), read.bytes)
+	}
+
+	// Sprawdź czy plik się zmienił (może został przepisany) - tylko gdy
+	// aktywność nie jest oceniana po treści. Nowy plik po rotacji nie jest
+	// aktywnością, więc jego mtime tylko zapamiętujemy.
+	if !m.matcher.contentAware() && !read.rotated && read.modTime.After(m.logModTime) {
+		m.logf("Plik logów zaktualizowany: %s\n", read.modTime.Format("15:04:05"))
+		m.setLastActivity(time.Now())
+		m.logModTime = read.modTime
 		return true, nil
 	}
+	if !read.modTime.IsZero() {
+		m.logModTime = read.modTime
+	}
 
-	// Sprawdź czy plik się zmienił (może został przepisany)
-	if modTime.After(m.lastModTime) {
-		fmt.Printf("Plik logów zaktualizowany: %s\n", modTime.Format("15:04:05"))
-		m.lastModTime = modTime
-		m.lastLogSize = size
+	// W fazie startu cisza w logach nie jest błędem - obowiązuje limit startu
+	if !m.isStarted() {
 		return true, nil
 	}
 
 	// Sprawdź czy minął timeout bez zmian
 	timeSinceLastChange := time.Since(m.lastModTime)
 	if timeSinceLastChange > m.timeout {
-		fmt.Printf("TIMEOUT! Brak zmian w logach przez %v (limit: %v)\n", 
+		m.logf("TIMEOUT! Brak zmian w logach przez %v (limit: %v)\n", 
 			timeSinceLastChange.Round(time.Second), m.timeout)
+		m.emitLogTimeout(timeSinceLastChange)
 		return false, nil
 	}
 
 	// Pokazuj co jakiś czas status oczekiwania
 	if int(timeSinceLastChange.Seconds())%30 == 0 && timeSinceLastChange > 30*time.Second {
-		fmt.Printf("Oczekiwanie na zmiany w logach... (%v/%v)\n", 
+		m.logf("Oczekiwanie na zmiany w logach... (%v/%v)\n", 
 			timeSinceLastChange.Round(time.Second), m.timeout)
 	}
 
 	return true, nil
 }
 
-// Uruchamia nowy proces
-func (m *Monitor) startProcess() error {
+// Uruchamia nowy proces. Procesy uruchamia i zatrzymuje tylko pętla
+// nadzoru (lub supervisor przed jej startem), więc zatrzymanie poprzedniego
+// procesu i hook pre_start mogą działać bez mutexu.
+func (m *Monitor) startProcess() (err error) {
+	// Błąd uruchomienia jest przyczyną restartu, a nie zakończeniem procesu
+	defer func() {
+		m.mutex.Lock()
+		m.startErr = err
+		m.mutex.Unlock()
+	}()
+
+	// Jeśli jakiś proces już działa, zabij go
+	m.killProcess()
+
+	// Hook przed startem (np. usunięcie nieaktualnego pliku PID) może zablokować start
+	if err := m.runPreStart(); err != nil {
+		m.mutex.Lock()
+		m.state = stateStopped
+		m.mutex.Unlock()
+		return err
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	// Jeśli jakiś proces już działa, zabij go
-	if m.process != nil {
-		m.killProcessUnsafe()
+	m.logf("Uruchamianie: %s\n", m.command)
+
+	// Środowisko i tożsamość procesu - użytkownik mógł zniknąć, a env_file
+	// zmienić się od poprzedniego startu
+	cred, runAs, err := resolveCredential(m.runUser, m.runGroup)
+	var env []string
+	if err == nil {
+		env, err = m.processEnv(runAs)
+	}
+
+	// Tworzenie komendy do wykonania - bez kontekstu, bo anulowanie
+	// kontekstu wysłałoby od razu SIGKILL z pominięciem SIGTERM
+	if err == nil {
+		m.process, err = m.buildCommand(env)
+	}
+	if err != nil {
+		m.process = nil
+		m.state = stateStopped
+		return err
+	}
+	m.process.Dir = m.workingDir
+	m.process.Env = env
+
+	// Własna grupa procesów (lub sesja) - zatrzymanie obejmie całe drzewo,
+	// a nie tylko powłokę
+	if m.newSession {
+		m.process.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	} else {
+		m.process.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+	m.process.SysProcAttr.Credential = cred
+
+	// Własna cgroup z limitami - proces trafia do niej już przy tworzeniu
+	if m.cgroupCfg != nil {
+		cg, err := newCgroup(m.name, m.cgroupCfg)
+		if err != nil {
+			m.process = nil
+			m.state = stateStopped
+			return fmt.Errorf("cgroup: %v", err)
+		}
+		dir, err := os.Open(cg.path)
+		if err != nil {
+			cg.remove(defaultKillWait)
+			m.process = nil
+			m.state = stateStopped
+			return fmt.Errorf("cgroup: %v", err)
+		}
+		defer dir.Close()
+		m.process.SysProcAttr.UseCgroupFD = true
+		m.process.SysProcAttr.CgroupFD = int(dir.Fd())
+		m.cgroup = cg
+	}
+
+	// Wyjście procesu trafia do pliku logów zarządzanego przez monitor
+	m.flushStreams()
+	var pipes []*os.File
+	if m.output != nil {
+		for len(pipes) < 2 && err == nil {
+			var pipe *os.File
+			var stream *lineWriter
+			if pipe, stream, err = m.output.newStream(); err == nil {
+				pipes = append(pipes, pipe)
+				m.streams = append(m.streams, stream)
+			}
+		}
+		if err == nil {
+			m.process.Stdout, m.process.Stderr = pipes[0], pipes[1]
+		}
 	}
 
-	fmt.Printf("Uruchamianie: %s\n", m.command)
-	
-	// Tworzenie komendy do wykonania z kontekstem
-	m.process = exec.CommandContext(m.ctx, "sh", "-c", m.command)
-	
 	// Uruchomienie procesu w tle
-	err := m.process.Start()
+	if err == nil {
+		err = startWithUmask(m.process.Start, m.umask)
+	}
+	// Końce potoków do zapisu ma już proces potomny
+	for _, pipe := range pipes {
+		pipe.Close()
+	}
 	if err != nil {
-		return fmt.Errorf("nie można uruchomić procesu: %v", err)
+		m.process = nil
+		m.state = stateStopped
+		m.releaseCgroup(m.detachCgroupUnsafe())
+		return startError(err)
 	}
+	m.exit = waitForExit(m.process, m.exits)
 
-	fmt.Printf("Proces uruchomiony z PID: %d\n", m.process.Process.Pid)
+	m.logf("Proces uruchomiony z PID: %d\n", m.process.Process.Pid)
+	m.emit(Event{Type: eventStarted, PID: m.process.Process.Pid})
 	
 	// Reset metryk - nowy proces = nowy start
 	m.lastModTime = time.Now()
+	m.errorReason = ""
+	m.matcher.reset()
+	m.startedAt = time.Now()
+	m.watchdog.reset()
+	m.usage = resourceUsage{}
+	m.leak = leakTracker{}
+	m.leakPlan = leakPrediction{}
+	m.beginStartupUnsafe()
+	m.startProbesUnsafe()
+	m.runPostStartUnsafe(m.process.Process.Pid)
 	
 	return nil
 }
 
-// Zabija proces - wersja bez locka (używana wewnętrznie)
-func (m *Monitor) killProcessUnsafe() {
+// Zatrzymuje proces. Mutex jest trzymany tylko przy odczycie i zmianie
+// stanu - akcja przed zatrzymaniem, kolejne sygnały, sprzątanie potomków
+// i hooki działają bez niego, żeby status, metryki i sygnały operatora
+// nie czekały na koniec zatrzymania.
+func (m *Monitor) killProcess() {
+	m.mutex.Lock()
 	if m.process == nil || m.process.Process == nil {
+		m.mutex.Unlock()
 		return
 	}
 
-	pid := m.process.Process.Pid
-	fmt.Printf("Zatrzymywanie procesu PID: %d\n", pid)
-	
-	// Wyślij SIGTERM (grzeczne zamknięcie)
-	err := m.process.Process.Signal(syscall.SIGTERM)
-	if err != nil {
-		fmt.Printf("Błąd wysyłania SIGTERM: %v\n", err)
+	// Proces już się zakończył - wystarczy wyczyścić referencję
+	exit := m.exit
+	if exit.exited() {
+		m.clearProcessUnsafe()
+		cg := m.detachCgroupUnsafe()
+		m.mutex.Unlock()
+		m.releaseCgroup(cg)
+		m.runPostStop(exit)
 		return
 	}
-	
-	// Uruchom goroutine która czeka na zakończenie procesu
-	done := make(chan error, 1)
-	go func() {
-		done <- m.process.Wait()
-	}()
 
-	// Czekaj maksymalnie 5 sekund na grzeczne zamknięcie
-	select {
-	case err := <-done:
-		if err != nil {
-			fmt.Printf("Proces zakończony z błędem: %v\n", err)
+	pid := m.process.Process.Pid
+	exit.expected = true
+	m.state = stateStopping
+	m.stopProbesUnsafe()
+	m.mutex.Unlock()
+	m.logf("Zatrzymywanie procesu PID: %d\n", pid)
+
+	// Akcja przed zatrzymaniem (np. drenaż ruchu)
+	m.runPreStop(pid)
+
+	// Migawka drzewa procesów - potomkowie mogą opuścić grupę
+	tree := processTree(pid)
+
+	// Kolejne kroki sygnał/czas - goroutine z waitForExit zamknie kanał
+	// done po zakończeniu procesu
+	result := m.runStopSequence(pid, tree, exit.done)
+	if exit.exited() {
+		if exit.err != nil {
+			m.logf("Proces zakończony z błędem: %v\n", exit.err)
 		} else {
-			fmt.Println("Proces zakończony poprawnie")
+			m.logf("Proces zakończony poprawnie\n")
 		}
-	case <-time.After(5 * time.Second):
-		// Timeout - zabij na siłę
-		fmt.Println("Wymuszanie zakończenia procesu (SIGKILL)...")
-		if m.process.Process != nil {
-			m.process.Process.Kill()
-			// Daj trochę czasu na cleanup, ale nie czekaj w nieskończoność
-			select {
-			case <-done:
-			case <-time.After(2 * time.Second):
-				fmt.Println("Proces może nie zostać prawidłowo zamknięty")
-			}
+	}
+
+	m.mutex.Lock()
+	m.lastStop = result
+	m.stops++
+	if result.killed {
+		m.stopsKilled++
+	}
+	m.clearProcessUnsafe()
+	cg := m.detachCgroupUnsafe()
+	m.mutex.Unlock()
+
+	// Zatrzymanie jest zakończone dopiero gdy nie przetrwał żaden potomek -
+	// z cgroup rozstrzyga cgroup.kill (obejmuje też procesy spoza drzewa)
+	stopped := false
+	if cg != nil {
+		stopped = m.releaseCgroup(cg)
+	} else {
+		stopped = m.ensureTreeStopped(pid, tree, m.killGrace)
+	}
+	if stopped {
+		m.logf("Zatrzymanie zakończone - brak działających procesów potomnych\n")
+	}
+	m.runPostStop(exit)
+}
+
+// Czyści referencję do zakończonego procesu, zapamiętując wynik zakończenia
+func (m *Monitor) clearProcessUnsafe() {
+	if m.exit != nil && m.exit.exited() {
+		if m.cgroup != nil && m.cgroup.oomKilled() {
+			m.exit.oomKilled = true
 		}
-		fmt.Println("Proces zakończony wymuszenie")
+		m.lastExit = m.exit
+		m.emit(exitEvent(m.exit))
 	}
-	
 	m.process = nil
+	m.exit = nil
+	m.state = stateStopped
+	m.stopProbesUnsafe()
+	m.flushStreams()
 }
 
-// Zabija proces - bezpieczna wersja publiczna
-func (m *Monitor) killProcess() {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-	m.killProcessUnsafe()
+// Zapisuje niezakończone linie wyjścia poprzedniego procesu
+func (m *Monitor) flushStreams() {
+	for _, w := range m.streams {
+		w.flush()
+	}
+	m.streams = nil
 }
 
-// Sprawdza czy proces jeszcze żyje
+// Sprawdza czy proces jeszcze żyje - proces jest zbierany przez goroutine
+// z waitForExit, więc wystarczy sprawdzić czy już się zakończył
 func (m *Monitor) isProcessRunning() bool {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
-	
-	if m.process == nil || m.process.Process == nil {
-		return false
-	}
 
-	// Sprawdź czy proces się już zakończył
-	select {
-	case <-m.ctx.Done():
-		return false
-	default:
-	}
+	return m.process != nil && !m.exit.exited()
+}
 
-	// Wyślij sygnał 0 - nie zabija procesu, tylko sprawdza czy istnieje
-	err := m.process.Process.Signal(syscall.Signal(0))
-	if err != nil {
-		// Proces nie istnieje, wyczyść referencję
-		m.process = nil
-		return false
+// Sprawdza czy proces nie działa. Jeśli właśnie się zakończył, czyści
+// referencję i zwraca wynik zakończenia (nil gdy proces nie był uruchomiony).
+func (m *Monitor) collectExit() (*processExit, bool) {
+	m.mutex.Lock()
+	if m.process == nil {
+		m.mutex.Unlock()
+		return nil, true
+	}
+	if !m.exit.exited() {
+		m.mutex.Unlock()
+		return nil, false
+	}
+	exit := m.exit
+	m.clearProcessUnsafe()
+	cg := m.detachCgroupUnsafe()
+	m.mutex.Unlock()
+
+	// Główny proces zakończył się sam - nie zostawiaj osieroconych potomków,
+	// których zapisy do logów maskowałyby restart. Tylko procesy z migawki
+	// sprzed zebrania głównego procesu, bo pusta grupa mogła zmienić właściciela.
+	if len(exit.orphans) > 0 {
+		m.ensureTreeStopped(exit.pid, exit.orphans, m.killGrace)
 	}
-	return true
+	m.releaseCgroup(cg)
+	m.runPostStop(exit)
+	return exit, true
+}
+
+// Czy zdarzenie dotyczy bieżącego procesu, który zakończył się sam
+// (a nie na polecenie monitora)
+func (m *Monitor) isUnexpectedExit(exit *processExit) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return exit == m.exit && !exit.expected
 }
 
 // Waliduje parametry i przygotowuje środowisko
 func (m *Monitor) validate() error {
+	if err := m.prepareLog(); err != nil {
+		return err
+	}
+	return m.openFiles()
+}
+
+// Sprawdza, czy plik logów da się otworzyć, tworząc go w razie potrzeby.
+// Nie zostawia otwartych plików - przy przeładowaniu stary monitor może
+// jeszcze pisać do tego samego pliku.
+func (m *Monitor) prepareLog() error {
 	// Sprawdź czy katalog dla pliku logów istnieje
 	logDir := filepath.Dir(m.logFile)
 	if err := os.MkdirAll(logDir, 0755); err != nil {
@@ -219,7 +599,7 @@ func (m *Monitor) validate() error {
 
 	// Sprawdź czy plik logów istnieje (jeśli nie, spróbuj go utworzyć)
 	if _, err := os.Stat(m.logFile); os.IsNotExist(err) {
-		fmt.Printf("Plik logów nie istnieje, tworzę: %s\n", m.logFile)
+		m.logf("Plik logów nie istnieje, tworzę: %s\n", m.logFile)
 		if file, err := os.Create(m.logFile); err != nil {
 			return fmt.Errorf("nie można utworzyć pliku logów: %v", err)
 		} else {
@@ -227,88 +607,168 @@ func (m *Monitor) validate() error {
 		}
 	}
 
+	// Przechwytywanie wyjścia wymaga prawa zapisu
+	flag := os.O_RDONLY
+	if m.outputCfg.Capture {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(m.logFile, flag, 0)
+	if err != nil {
+		return fmt.Errorf("nie można otworzyć pliku logów: %v", err)
+	}
+	file.Close()
 	return nil
 }
 
-// Główna pętla monitora
-func (m *Monitor) Run() {
-	fmt.Println("Uruchamianie monitora procesów...")
-	fmt.Printf("Plik logów: %s\n", m.logFile)
-	fmt.Printf("Timeout: %v\n", m.timeout)
-	fmt.Printf("Interwał sprawdzania: %v\n", m.interval)
-	fmt.Println("Aby zatrzymać monitor, naciśnij Ctrl+C")
-	fmt.Println("--------------------------------------------------")
+// Otwiera plik logów do śledzenia albo do zapisu przechwyconego wyjścia
+func (m *Monitor) openFiles() error {
+	// Monitor sam zapisuje wyjście procesu - nie trzeba śledzić pliku
+	if m.outputCfg.Capture {
+		output, err := newOutputCapture(m.logFile, m.outputCfg, m.logf)
+		if err != nil {
+			return fmt.Errorf("nie można otworzyć pliku logów do zapisu: %v", err)
+		}
+		m.output = output
+		return nil
+	}
 
-	// Walidacja parametrów
-	if err := m.validate(); err != nil {
-		log.Fatalf("Błąd walidacji: %v", err)
+	// Wcześniejsza zawartość logów nie jest analizowana - czytamy tylko nowe wpisy
+	if err := m.openLog(); err != nil {
+		return fmt.Errorf("nie można otworzyć pliku logów: %v", err)
 	}
 
-	// Obsługa sygnałów systemowych (Ctrl+C, kill)
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	return nil
+}
 
-	// Uruchom proces po raz pierwszy
-	if err := m.startProcess(); err != nil {
-		log.Fatalf("Błąd uruchamiania: %v", err)
+// Zamyka pliki logów otwarte przez openFiles
+func (m *Monitor) closeFiles() {
+	m.closeLog()
+	if m.output != nil {
+		m.output.close()
 	}
+}
+
+// Pętla nadzoru jednego programu - działa aż do anulowania kontekstu
+func (m *Monitor) watch(ctx context.Context) {
+	defer close(m.stopped)
 
 	// Timer sprawdzający stan co określony interwał
 	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
 
-	// Główna pętla
+	// Timer opóźnionego restartu (nil gdy nie czekamy na restart)
+	var restartTimer <-chan time.Time
+
 	for {
 		select {
-		case sig := <-sigChan:
-			// Otrzymano sygnał zamknięcia
-			fmt.Printf("\nOtrzymano sygnał %v, zamykanie monitora...\n", sig)
-			m.cancel()
+		case <-ctx.Done():
+			// Supervisor kończy pracę - zatrzymaj proces
 			m.killProcess()
-			fmt.Println("Monitor zakończony")
+			m.closeFiles()
+			m.logf("Nadzór zakończony\n")
 			return
 
-		case <-m.ctx.Done():
-			// Kontekst został anulowany
-			m.killProcess()
-			fmt.Println("Monitor zakończony przez kontekst")
-			return
+		case exit := <-m.exits:
+			// Proces zakończył się - reaguj od razu, bez czekania na interwał
+			if !m.isUnexpectedExit(exit) {
+				continue
+			}
+			if collected, dead := m.collectExit(); dead {
+				restartTimer = m.handleDeath(collected)
+			}
+
+		case req := <-m.requests:
+			// Polecenie operatora z gniazda sterującego
+			restartTimer = m.handleRequest(req, restartTimer)
+
+		case <-restartTimer:
+			// Minęło opóźnienie - uruchom proces ponownie
+			restartTimer = nil
+			if m.isUserStopped() {
+				continue
+			}
+			if err := m.startProcess(); err != nil {
+				m.logf("Błąd restartu: %v\n", err)
+				restartTimer = m.requestRestart(causeStartError, fmt.Sprintf("błąd uruchomienia: %v", err))
+				continue
+			}
+			m.logf("Proces zrestartowany pomyślnie\n")
 
 		case <-ticker.C:
+			// Podczas oczekiwania na restart, w pętli awarii, po zakończeniu
+			// nie wymagającym restartu i po zatrzymaniu na żądanie nie sprawdzamy stanu
+			if restartTimer != nil || m.isUserStopped() {
+				continue
+			}
+			if state := m.State(); state == stateCrashLoop || state == stateExited {
+				continue
+			}
+			m.resetBackoffIfStable()
+
+			// 1. Sprawdź czy proces jeszcze żyje (zapasowo - zakończenie
+			// zwykle obsługuje już zdarzenie z kanału exits)
+			if exit, dead := m.collectExit(); dead {
+				restartTimer = m.handleDeath(exit)
+				continue
+			}
+
+			// Wstrzymany nadzór - logi są czytane, ale nie powodują restartu
+			if m.isPaused() {
+				m.drainPaused()
+				continue
+			}
+
 			// Czas na kolejne sprawdzenie
 			needRestart := false
-			reason := ""
+			cause, reason := "", ""
 
-			// 1. Sprawdź czy proces jeszcze żyje
-			if !m.isProcessRunning() {
+			// 2. Sprawdź aktywność w logach, wzorce błędów, sondy liveness i zasoby
+			logOk, err := m.checkLogs()
+			if err != nil {
+				m.logf("Błąd sprawdzania logów: %v\n", err)
+				continue
+			}
+			usage := m.sampleResources()
+			if trigger := m.takeErrorTrigger(); trigger != "" {
+				needRestart = true
+				cause, reason = causeErrorPattern, trigger
+			} else if failure := m.checkStartup(); failure != "" {
+				needRestart = true
+				cause, reason = causeStartup, failure
+			} else if failure := m.takeProbeFailure(); failure != "" {
 				needRestart = true
-				reason = "proces przestał działać"
+				cause, reason = causeProbe, failure
+			} else if failure := m.checkResources(usage); failure != "" {
+				needRestart = true
+				cause, reason = causeResource, failure
+			} else if !logOk {
+				needRestart = true
+				cause, reason = causeLogTimeout, "brak aktywności w logach"
 			}
 
-			// 2. Sprawdź aktywność w logach (tylko jeśli proces żyje)
-			if !needRestart {
-				logOk, err := m.checkLogs()
-				if err != nil {
-					log.Printf("Błąd sprawdzania logów: %v", err)
-					continue
-				}
-				if !logOk {
-					needRestart = true
-					reason = "brak aktywności w logach"
-				}
+			// 3. Diagnostyka i hook on_crash, dopóki zawieszony proces jeszcze działa
+			if needRestart {
+				diagDir := m.captureDiagnostics(cause, reason)
+				m.runCrashHook(cause, reason, nil, diagDir)
 			}
 
-			// 3. Jeśli trzeba, restartuj proces
+			// 4. Jeśli trzeba, restartuj proces (z opóźnieniem wg polityki)
+			if needRestart && m.restartCfg.Policy == restartPolicyNever {
+				m.logf("Proces nie odpowiada (%s) - polityka %s, zatrzymywanie bez restartu\n",
+					reason, m.restartCfg.Policy)
+				m.killProcess()
+				m.markExited(nil)
+				continue
+			}
 			if needRestart {
-				fmt.Printf("Restartowanie procesu - powód: %s\n", reason)
-				
-				if err := m.startProcess(); err != nil {
-					log.Printf("Błąd restartu: %v", err)
-					// Spróbuj ponownie za interwał
-					continue
-				}
-				
-				fmt.Println("Proces zrestartowany pomyślnie")
+				m.logf("Restartowanie procesu - powód: %s\n", reason)
+				restartTimer = m.requestRestart(cause, reason)
+				continue
+			}
+
+			// 5. Restart zaplanowany przed przewidywanym wyczerpaniem pamięci
+			if reason := m.observeLeak(usage); reason != "" {
+				restartTimer = m.plannedRestart(causeMemoryLeak, reason)
 			}
 		}
 	}
@@ -317,66 +777,204 @@ func (m *Monitor) Run() {
 // Wyświetla instrukcję użycia
 func printUsage(progName string) {
 	fmt.Printf("🔍 Monitor Procesów - automatyczny restart przy braku aktywności\n\n")
-	fmt.Printf("Użycie: %s <komenda> <plik_logów> [timeout_sek] [interwał_sek]\n\n", progName)
+	fmt.Printf("Użycie: %s [opcje] <komenda> <plik_logów> [timeout_sek] [interwał_sek]\n", progName)
+	fmt.Printf("        %s [opcje] <plik_logów> [timeout_sek] [interwał_sek] -- <program> [argumenty...]\n", progName)
+	fmt.Printf("        %s --config <plik.json> [opcje]\n\n", progName)
 	fmt.Printf("Parametry:\n")
-	fmt.Printf("  komenda      - aplikacja do monitorowania (w cudzysłowach)\n")
+	fmt.Printf("  komenda      - komenda powłoki do monitorowania (w cudzysłowach)\n")
+	fmt.Printf("  -- program   - program z argumentami uruchamiany bezpośrednio, bez powłoki\n")
 	fmt.Printf("  plik_logów   - ścieżka do pliku z logami\n")
 	fmt.Printf("  timeout_sek  - restart po X sekundach bez zmian (domyślnie: 60)\n")
 	fmt.Printf("  interwał_sek - sprawdzaj co X sekund (domyślnie: 5)\n\n")
+	fmt.Printf("Opcje (nadpisują wartości z pliku konfiguracyjnego dla wszystkich programów):\n")
+	fmt.Printf("  --config <plik>     - plik konfiguracyjny JSON z listą programów\n")
+	fmt.Printf("  --timeout <czas>    - np. 90s, 2m\n")
+	fmt.Printf("  --interval <czas>   - np. 5s\n")
+	fmt.Printf("  --kill-grace <czas> - czas między SIGTERM a SIGKILL (domyślnie: 5s)\n")
+	fmt.Printf("  --workdir <katalog> - katalog roboczy procesu\n")
+	fmt.Printf("  --shell <interpr.>  - interpreter komendy powłoki (domyślnie: sh), np. bash\n")
+	fmt.Printf("  --socket <ścieżka>  - gniazdo sterujące (domyślnie: %s)\n", defaultControlSocket())
+	fmt.Printf("  --metrics <adres>   - endpoint metryk Prometheus, np. :9100\n")
+	fmt.Printf("  --events <plik>     - dziennik zdarzeń JSON lines (plik lub stderr)\n")
+	fmt.Printf("  --human=false       - bez komunikatów tekstowych na stdout\n\n")
+	fmt.Printf("Polecenia dla działającego monitora (opcje: --socket, --json):\n")
+	fmt.Printf("  %s status [program]\n", progName)
+	fmt.Printf("  %s start|stop|restart|pause|resume <program>\n", progName)
+	fmt.Printf("  %s signal <program> <SYGNAŁ>\n", progName)
+	fmt.Printf("  %s logs [-f] [-n linii] <program>\n", progName)
+	fmt.Printf("  %s events [program]\n", progName)
+	fmt.Printf("  %s reload\n\n", progName)
 	fmt.Printf("Przykłady:\n")
 	fmt.Printf("  %s \"python3 app.py > /tmp/app.log 2>&1\" \"/tmp/app.log\"\n", progName)
 	fmt.Printf("  %s \"java -jar app.jar\" \"/var/log/app.log\" 120 10\n", progName)
 	fmt.Printf("  %s \"./moj_skrypt.sh\" \"/tmp/output.log\" 30 3\n", progName)
+	fmt.Printf("  %s /var/log/app.log 120 -- java -jar app.jar\n", progName)
+	fmt.Printf("  %s --config /etc/monitor.json --kill-grace 30s\n", progName)
 	fmt.Printf("\nNotatki:\n")
 	fmt.Printf("  • Monitor restartuje proces gdy logi nie zmieniają się przez określony czas\n")
 	fmt.Printf("  • Proces jest najpierw grzecznie zamykany (SIGTERM), potem na siłę (SIGKILL)\n")
 	fmt.Printf("  • Katalogi dla pliku logów są tworzone automatycznie\n")
 	fmt.Printf("  • Aby zatrzymać monitor, użyj Ctrl+C\n")
+	fmt.Printf("  • SIGHUP lub polecenie reload przeładowuje plik konfiguracyjny\n")
 }
 
-func main() {
-	// Sprawdzenie argumentów
-	if len(os.Args) < 3 {
-		printUsage(os.Args[0])
-		os.Exit(1)
-	}
-
+// Buduje konfigurację z argumentów pozycyjnych (tryb jednego programu).
+// Argumenty po "--" to program uruchamiany bez powłoki. Zwraca nil,
+// gdy brakuje komendy lub pliku logów.
+func configFromArgs(args []string) *Config {
 	// Parsowanie argumentów
-	command := os.Args[1]
-	logFile := os.Args[2]
+	var command string
+	var argv []string
+	for i, a := range args {
+		if a == "--" {
+			args, argv = args[:i], args[i+1:]
+			break
+		}
+	}
+	if argv == nil {
+		if len(args) < 2 {
+			return nil
+		}
+		command, args = args[0], args[1:]
+	} else if len(argv) == 0 || len(args) < 1 {
+		return nil
+	}
+	logFile := args[0]
 
 	// Domyślne wartości
 	timeout := 60  // 60 sekund timeout
 	interval := 5  // sprawdzaj co 5 sekund
 
 	// Opcjonalne argumenty
-	if len(os.Args) > 3 {
-		if t, err := strconv.Atoi(os.Args[3]); err == nil && t > 0 {
+	if len(args) > 1 {
+		if t, err := strconv.Atoi(args[1]); err == nil && t > 0 {
 			timeout = t
 		} else {
-			fmt.Printf("Nieprawidłowy timeout '%s', używam domyślnego: %d\n", os.Args[3], timeout)
+			fmt.Printf("Nieprawidłowy timeout '%s', używam domyślnego: %d\n", args[1], timeout)
 		}
 	}
 
-	if len(os.Args) > 4 {
-		if i, err := strconv.Atoi(os.Args[4]); err == nil && i > 0 {
+	if len(args) > 2 {
+		if i, err := strconv.Atoi(args[2]); err == nil && i > 0 {
 			interval = i
 		} else {
-			fmt.Printf("Nieprawidłowy interwał '%s', używam domyślnego: %d\n", os.Args[4], interval)
+			fmt.Printf("Nieprawidłowy interwał '%s', używam domyślnego: %d\n", args[2], interval)
 		}
 	}
 
-	// Walidacja parametrów
-	if timeout < interval {
-		fmt.Printf("⚠️  Timeout (%d) jest mniejszy niż interwał (%d), może prowadzić do częstych restartów\n", timeout, interval)
+	program := ProgramConfig{
+		Name:     "main",
+		Command:  command,
+		Args:     argv,
+		LogFile:  logFile,
+		Timeout:  Duration{time.Duration(timeout) * time.Second},
+		Interval: Duration{time.Duration(interval) * time.Second},
 	}
+	program.applyDefaults()
 
-	if interval < 1 {
-		fmt.Printf("⚠️  Interwał (%d) jest zbyt mały, ustawiam minimum 1 sekunda\n", interval)
-		interval = 1
+	cfg := &Config{Version: configVersion, Programs: []ProgramConfig{program}}
+	cfg.Control.applyDefaults()
+	cfg.Metrics.applyDefaults()
+	return cfg
+}
+
+func main() {
+	// Podkomendy klienta łączą się z już działającym monitorem
+	if len(os.Args) > 1 && isClientCommand(os.Args[1]) {
+		os.Exit(runClient(os.Args[1:]))
+	}
+
+	flag.Usage = func() { printUsage(os.Args[0]) }
+	configPath := flag.String("config", "", "plik konfiguracyjny JSON")
+	timeoutFlag := flag.Duration("timeout", 0, "timeout bez zmian w logach")
+	intervalFlag := flag.Duration("interval", 0, "interwał sprawdzania")
+	killGraceFlag := flag.Duration("kill-grace", 0, "czas między SIGTERM a SIGKILL")
+	workDirFlag := flag.String("workdir", "", "katalog roboczy procesu")
+	shellFlag := flag.String("shell", "", "interpreter komendy powłoki, np. bash")
+	socketFlag := flag.String("socket", "", "ścieżka gniazda sterującego")
+	metricsFlag := flag.String("metrics", "", "adres endpointu metryk Prometheus, np. :9100")
+	eventsFlag := flag.String("events", "", "dziennik zdarzeń JSON lines: plik lub stderr")
+	humanFlag := flag.Bool("human", true, "komunikaty tekstowe na stdout")
+	flag.Parse()
+
+	// Flagi ustawione jawnie nadpisują wartości z pliku
+	applyFlags := func(cfg *Config) {
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "socket":
+				cfg.Control.Socket = *socketFlag
+			case "metrics":
+				cfg.Metrics.Listen = *metricsFlag
+			case "events":
+				cfg.Events.File = *eventsFlag
+			case "human":
+				cfg.Events.Human = humanFlag
+			}
+			for i := range cfg.Programs {
+				p := &cfg.Programs[i]
+				switch f.Name {
+				case "timeout":
+					p.Timeout.Duration = *timeoutFlag
+				case "interval":
+					p.Interval.Duration = *intervalFlag
+				case "kill-grace":
+					p.KillGrace.Duration = *killGraceFlag
+				case "workdir":
+					p.WorkingDir = *workDirFlag
+				case "shell":
+					// Programy w trybie args działają bez powłoki
+					if p.Command != "" {
+						p.Shell = *shellFlag
+					}
+				}
+			}
+		})
+	}
+
+	var cfg *Config
+	var loader func() (*Config, error)
+	if *configPath != "" {
+		// Ta sama funkcja wczytuje konfigurację przy przeładowaniu
+		loader = func() (*Config, error) {
+			loaded, err := LoadConfig(*configPath)
+			if err != nil {
+				return nil, err
+			}
+			applyFlags(loaded)
+			return loaded, nil
+		}
+		loaded, err := loader()
+		if err != nil {
+			log.Fatalf("Błąd konfiguracji: %v", err)
+		}
+		cfg = loaded
+	} else {
+		// Sprawdzenie argumentów
+		cfg = configFromArgs(flag.Args())
+		if cfg == nil {
+			printUsage(os.Args[0])
+			os.Exit(1)
+		}
+		applyFlags(cfg)
 	}
 
-	// Utworzenie i uruchomienie monitora
-	monitor := NewMonitor(command, logFile, timeout, interval)
-	monitor.Run()
-}
\ No newline at end of file
+	// Walidacja parametrów
+	for _, p := range cfg.Programs {
+		if p.Timeout.Duration < p.Interval.Duration {
+			fmt.Printf("⚠️  [%s] Timeout (%v) jest mniejszy niż interwał (%v), może prowadzić do częstych restartów\n",
+				p.Name, p.Timeout.Duration, p.Interval.Duration)
+		}
+	}
+
+	// Utworzenie i uruchomienie supervisora
+	supervisor, err := NewSupervisor(cfg.Programs)
+	if err != nil {
+		log.Fatalf("Błąd konfiguracji: %v", err)
+	}
+	supervisor.SetControl(cfg.Control)
+	supervisor.SetMetrics(cfg.Metrics)
+	supervisor.SetEvents(cfg.Events)
+	supervisor.SetNotifiers(cfg.Notifiers)
+	supervisor.SetLoader(loader)
+	os.Exit(supervisor.Run())
+}
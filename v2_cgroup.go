@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Okres limitu CPU (cpu.max) w mikrosekundach, gdy limit podano w rdzeniach
+const cgroupCPUPeriod = 100000
+
+// Zakresy cpu.max akceptowane przez jądro (mikrosekundy): kwota co najmniej
+// 1ms, okres od 1ms do 1s
+const (
+	cgroupCPUMinQuota  = 1000
+	cgroupCPUMinPeriod = 1000
+	cgroupCPUMaxPeriod = 1000000
+)
+
+// Jak często sprawdzać, czy cgroup jest już pusta
+const cgroupPollInterval = 20 * time.Millisecond
+
+// Limity zasobów programu w osobnej cgroup v2
+type CgroupConfig struct {
+	MemoryMax string `json:"memory_max"` // Limit pamięci: bajty lub z przyrostkiem K/M/G/T, np. "512M"
+	CPUMax    string `json:"cpu_max"`    // Limit CPU w rdzeniach, np. "1.5", lub wprost "kwota okres"
+	PidsMax   int    `json:"pids_max"`   // Limit liczby procesów i wątków (0 = bez limitu)
+}
+
+// Sprawdza poprawność limitów cgroup
+func (c *CgroupConfig) validate() error {
+	if _, err := c.memoryMax(); err != nil {
+		return fmt.Errorf("cgroup: %v", err)
+	}
+	if _, err := c.cpuMax(); err != nil {
+		return fmt.Errorf("cgroup: %v", err)
+	}
+	if c.PidsMax < 0 {
+		return fmt.Errorf("cgroup: pids_max nie może być ujemny")
+	}
+	return nil
+}
+
+// Wartość memory.max (pusta gdy bez limitu)
+func (c *CgroupConfig) memoryMax() (string, error) {
+	s := strings.TrimSpace(c.MemoryMax)
+	if s == "" || s == "max" {
+		return s, nil
+	}
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch strings.ToUpper(s[n-1:]) {
+		case "K":
+			mult = 1 << 10
+		case "M":
+			mult = 1 << 20
+		case "G":
+			mult = 1 << 30
+		case "T":
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v <= 0 || v > math.MaxInt64/mult {
+		return "", fmt.Errorf("nieprawidłowy memory_max %q (oczekiwano np. \"512M\" lub \"max\")", c.MemoryMax)
+	}
+	return strconv.FormatInt(v*mult, 10), nil
+}
+
+// Wartość cpu.max (pusta gdy bez limitu)
+func (c *CgroupConfig) cpuMax() (string, error) {
+	s := strings.TrimSpace(c.CPUMax)
+	if s == "" || s == "max" {
+		return s, nil
+	}
+	if fields := strings.Fields(s); len(fields) == 2 {
+		quota, err1 := strconv.ParseInt(fields[0], 10, 64)
+		period, err2 := strconv.ParseInt(fields[1], 10, 64)
+		if (fields[0] == "max" || (err1 == nil && quota >= cgroupCPUMinQuota)) &&
+			err2 == nil && period >= cgroupCPUMinPeriod && period <= cgroupCPUMaxPeriod {
+			return fields[0] + " " + fields[1], nil
+		}
+	} else if cpus, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(cpus, 0) {
+		// Kwota poniżej minimum jądra (np. "0.001" rdzenia) jest błędem,
+		// a nie limitem zaokrąglonym do zera
+		if quota := cpus * cgroupCPUPeriod; quota >= cgroupCPUMinQuota && quota < math.MaxInt64 {
+			return fmt.Sprintf("%d %d", int64(quota), cgroupCPUPeriod), nil
+		}
+	}
+	return "", fmt.Errorf("nieprawidłowy cpu_max %q (oczekiwano rdzeni, np. \"1.5\", lub \"kwota okres\")", c.CPUMax)
+}
+
+// Katalog, w którym monitor tworzy cgroup programów - ustalany raz
+var cgroupBase struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// Zwraca katalog cgroup programów. Monitor działa we własnej cgroup
+// (np. usługa systemd z Delegate=yes); reguła "brak procesów w węzłach
+// wewnętrznych" v2 wymaga przeniesienia go do liścia "supervisor", zanim
+// kontrolery zostaną włączone dla poddrzewa.
+func cgroupRoot() (string, error) {
+	cgroupBase.once.Do(func() {
+		cgroupBase.path, cgroupBase.err = setupCgroupRoot()
+	})
+	return cgroupBase.path, cgroupBase.err
+}
+
+// Przygotowuje katalog cgroup programów
+func setupCgroupRoot() (string, error) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return "", err
+	}
+	own, err := ownCgroup()
+	if err != nil {
+		return "", err
+	}
+	parent := filepath.Join(mount, own)
+
+	if own != "/" {
+		leaf := filepath.Join(parent, "supervisor")
+		if err := os.Mkdir(leaf, 0o755); err != nil && !os.IsExist(err) {
+			return "", err
+		}
+		if err := writeCgroupFile(leaf, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+			return "", fmt.Errorf("nie można przenieść monitora do %s: %v", leaf, err)
+		}
+	}
+
+	base := filepath.Join(parent, "programs")
+	if err := os.Mkdir(base, 0o755); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	for _, dir := range []string{parent, base} {
+		if err := enableControllers(dir); err != nil {
+			return "", err
+		}
+	}
+	return base, nil
+}
+
+// Punkt montowania cgroup v2 z /proc/self/mountinfo
+func cgroup2Mount() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ... punkt_montowania ... - typ źródło opcje
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if field == "-" && i+1 < len(fields) && fields[i+1] == "cgroup2" && len(fields) > 4 {
+				return fields[4], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 nie jest zamontowana")
+}
+
+// Ścieżka cgroup v2 monitora z /proc/self/cgroup (wpis "0::/ścieżka")
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("brak wpisu cgroup v2 w /proc/self/cgroup")
+}
+
+// Włącza dla poddrzewa dostępne kontrolery memory, cpu i pids
+func enableControllers(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	var enable []string
+	for _, c := range strings.Fields(string(data)) {
+		if c == "memory" || c == "cpu" || c == "pids" {
+			enable = append(enable, "+"+c)
+		}
+	}
+	if len(enable) == 0 {
+		return nil
+	}
+	if err := writeCgroupFile(dir, "cgroup.subtree_control", strings.Join(enable, " ")); err != nil {
+		return fmt.Errorf("nie można włączyć kontrolerów w %s: %v", dir, err)
+	}
+	return nil
+}
+
+// Zapisuje wartość do pliku interfejsu cgroup. Plik musi istnieć - brak
+// pliku oznacza niedostępny kontroler (tworzenie w cgroupfs daje EACCES).
+func writeCgroupFile(dir, name, value string) error {
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Cgroup bieżącego procesu programu
+type cgroup struct {
+	path    string // Katalog cgroup
+	oomBase int64  // Licznik oom_kill przy tworzeniu
+}
+
+// Tworzy cgroup programu z limitami. Pozostałości po poprzednim procesie
+// (np. po awarii monitora) są najpierw zabijane i usuwane.
+func newCgroup(name string, cfg *CgroupConfig) (*cgroup, error) {
+	base, err := cgroupRoot()
+	if err != nil {
+		return nil, err
+	}
+	cg := &cgroup{path: filepath.Join(base, name)}
+	if _, err := os.Stat(cg.path); err == nil {
+		if err := cg.remove(defaultKillWait); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Mkdir(cg.path, 0o755); err != nil {
+		return nil, err
+	}
+
+	memory, _ := cfg.memoryMax()
+	cpu, _ := cfg.cpuMax()
+	limits := []struct{ file, value string }{
+		{"memory.max", memory},
+		{"cpu.max", cpu},
+	}
+	if cfg.PidsMax > 0 {
+		limits = append(limits, struct{ file, value string }{"pids.max", strconv.Itoa(cfg.PidsMax)})
+	}
+	// Przy OOM zabijana jest cała cgroup - bez częściowo działającego drzewa
+	if memory != "" {
+		limits = append(limits, struct{ file, value string }{"memory.oom.group", "1"})
+	}
+	for _, l := range limits {
+		if l.value == "" {
+			continue
+		}
+		if err := writeCgroupFile(cg.path, l.file, l.value); err != nil {
+			os.Remove(cg.path)
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s: kontroler niedostępny w %s", l.file, base)
+			}
+			return nil, fmt.Errorf("%s: %v", l.file, err)
+		}
+	}
+	cg.oomBase = cg.oomKills()
+	return cg, nil
+}
+
+// Licznik oom_kill z memory.events (0 gdy kontroler memory niedostępny)
+func (c *cgroup) oomKills() int64 {
+	data, err := os.ReadFile(filepath.Join(c.path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// Czy od utworzenia cgroup OOM killer zabił w niej jakiś proces
+func (c *cgroup) oomKilled() bool {
+	return c.oomKills() > c.oomBase
+}
+
+// Czy w cgroup są jeszcze procesy
+func (c *cgroup) populated() bool {
+	data, err := os.ReadFile(filepath.Join(c.path, "cgroup.events"))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), "populated 1")
+}
+
+// Zabija wszystkie procesy cgroup. Bez cgroup.kill (jądra < 5.14)
+// SIGKILL trafia do każdego procesu z cgroup.procs.
+func (c *cgroup) kill() error {
+	err := writeCgroupFile(c.path, "cgroup.kill", "1")
+	if err == nil {
+		return nil
+	}
+	data, rerr := os.ReadFile(filepath.Join(c.path, "cgroup.procs"))
+	if rerr != nil {
+		return err
+	}
+	for _, field := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+	return nil
+}
+
+// Zabija pozostałe procesy, czeka aż cgroup opustoszeje i ją usuwa
+func (c *cgroup) remove(wait time.Duration) error {
+	if c.populated() {
+		if err := c.kill(); err != nil {
+			return err
+		}
+		deadline := time.Now().Add(wait)
+		for c.populated() {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("procesy w %s przetrwały SIGKILL", c.path)
+			}
+			time.Sleep(cgroupPollInterval)
+		}
+	}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Odłącza cgroup bieżącego procesu (wywoływana z zablokowanym mutexem)
+func (m *Monitor) detachCgroupUnsafe() *cgroup {
+	cg := m.cgroup
+	m.cgroup = nil
+	return cg
+}
+
+// Zabija procesy pozostałe w odłączonej cgroup programu i usuwa ją.
+// Zwraca true gdy w cgroup nie został żaden proces. Może czekać na
+// zakończenie procesów, więc nie wymaga mutexu.
+func (m *Monitor) releaseCgroup(cg *cgroup) bool {
+	if cg == nil {
+		return true
+	}
+	if cg.populated() {
+		m.logf("Zabijanie procesów pozostałych w cgroup %s\n", cg.path)
+	}
+	if err := cg.remove(defaultKillWait); err != nil {
+		m.logf("UWAGA: nie można usunąć cgroup: %v\n", err)
+		return false
+	}
+	return true
+}
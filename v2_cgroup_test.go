@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestMemoryMax(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "", want: ""},
+		{spec: "max", want: "max"},
+		{spec: " max ", want: "max"},
+		{spec: "1048576", want: "1048576"},
+		{spec: "1K", want: "1024"},
+		{spec: "512M", want: "536870912"},
+		{spec: "512m", want: "536870912"},
+		{spec: "2G", want: "2147483648"},
+		{spec: "1T", want: "1099511627776"},
+		{spec: "8388607T", want: "9223370937343148032"},
+		{spec: "8388608T", wantErr: true}, // Przepełnienie int64
+		{spec: "0", wantErr: true},
+		{spec: "0M", wantErr: true},
+		{spec: "-1", wantErr: true},
+		{spec: "1.5G", wantErr: true},
+		{spec: "12X", wantErr: true},
+		{spec: "512MB", wantErr: true},
+		{spec: "MAX", wantErr: true},
+		{spec: "M", wantErr: true},
+		{spec: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := (&CgroupConfig{MemoryMax: tt.spec}).memoryMax()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: błąd = %v, oczekiwano błędu: %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%q = %q, oczekiwano %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestCPUMax(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "", want: ""},
+		{spec: "max", want: "max"},
+		{spec: "1", want: "100000 100000"},
+		{spec: "1.5", want: "150000 100000"},
+		{spec: "0.25", want: "25000 100000"},
+		{spec: "0.01", want: "1000 100000"},
+		{spec: "16", want: "1600000 100000"},
+		{spec: "max 100000", want: "max 100000"},
+		{spec: "50000 100000", want: "50000 100000"},
+		{spec: " 50000   100000 ", want: "50000 100000"},
+		{spec: "1000 1000", want: "1000 1000"},
+		{spec: "200000 1000000", want: "200000 1000000"},
+		{spec: "0", wantErr: true},
+		{spec: "0.0", wantErr: true},
+		{spec: "-1", wantErr: true},
+		{spec: "0.001", wantErr: true}, // Kwota poniżej 1ms
+		{spec: "Inf", wantErr: true},
+		{spec: "NaN", wantErr: true},
+		{spec: "abc", wantErr: true},
+		{spec: "1.5 100000", wantErr: true},
+		{spec: "0 100000", wantErr: true},
+		{spec: "999 100000", wantErr: true},
+		{spec: "50000 0", wantErr: true},
+		{spec: "50000 999", wantErr: true},
+		{spec: "50000 1000001", wantErr: true},
+		{spec: "50000 max", wantErr: true},
+		{spec: "1 2 3", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := (&CgroupConfig{CPUMax: tt.spec}).cpuMax()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: błąd = %v, oczekiwano błędu: %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%q = %q, oczekiwano %q", tt.spec, got, tt.want)
+		}
+	}
+}
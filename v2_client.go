@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Podkomendy klienta łączącego się z działającym monitorem
+var clientCommands = map[string]string{
+	"status":  "[program]",
+	"start":   "<program>",
+	"stop":    "<program>",
+	"restart": "<program>",
+	"signal":  "<program> <SYGNAŁ>",
+	"pause":   "<program>",
+	"resume":  "<program>",
+	"reload":  "",
+	"logs":    "[-f] [-n linii] <program>",
+	"events":  "[program]",
+}
+
+// Czy argument jest podkomendą klienta
+func isClientCommand(name string) bool {
+	_, ok := clientCommands[name]
+	return ok
+}
+
+// Wykonuje podkomendę klienta i zwraca kod wyjścia programu
+func runClient(args []string) int {
+	command := args[0]
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	socket := fs.String("socket", defaultControlSocket(), "ścieżka gniazda sterującego")
+	jsonOut := fs.Bool("json", false, "wynik w formacie JSON")
+	var follow *bool
+	var lines *int
+	if command == "logs" {
+		follow = fs.Bool("f", false, "śledzenie nowych linii")
+		lines = fs.Int("n", defaultLogLines, "liczba ostatnich linii")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Użycie: %s %s [--socket <ścieżka>] [--json] %s\n",
+			os.Args[0], command, clientCommands[command])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// Liczba argumentów pozycyjnych: wymagana i dopuszczalna
+	required, allowed := 0, 1
+	switch command {
+	case "start", "stop", "restart", "pause", "resume", "logs":
+		required = 1
+	case "signal":
+		required, allowed = 2, 2
+	case "reload":
+		allowed = 0
+	}
+	if fs.NArg() < required || fs.NArg() > allowed {
+		fs.Usage()
+		return 2
+	}
+	req := controlRequest{Command: command, Program: fs.Arg(0)}
+	if command == "signal" {
+		req.Signal = fs.Arg(1)
+	}
+	if follow != nil {
+		req.Follow = *follow
+		req.Lines = *lines
+	}
+
+	conn, err := net.Dial("unix", *socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Nie można połączyć się z monitorem (%s): %v\n", *socket, err)
+		return 1
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		fmt.Fprintf(os.Stderr, "Błąd wysyłania polecenia: %v\n", err)
+		return 1
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64<<10), 2*maxLogReadBytes)
+	responses := make(chan controlResponse)
+	go func() {
+		defer close(responses)
+		for scanner.Scan() {
+			var resp controlResponse
+			if json.Unmarshal(scanner.Bytes(), &resp) == nil {
+				responses <- resp
+			}
+		}
+	}()
+
+	first, ok := <-responses
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Monitor zamknął połączenie bez odpowiedzi\n")
+		return 1
+	}
+	if !first.OK {
+		fmt.Fprintf(os.Stderr, "Błąd: %s\n", first.Error)
+		return 1
+	}
+
+	switch command {
+	case "status":
+		printStatus(first.Programs, *jsonOut)
+	case "logs":
+		for resp := range responses {
+			printStreamed(resp, resp.Line, *jsonOut)
+		}
+	case "events":
+		for resp := range responses {
+			if resp.Event != nil {
+				printStreamed(resp, formatEvent(*resp.Event), *jsonOut)
+			}
+		}
+	default:
+		if *jsonOut {
+			printJSON(first)
+		} else if first.Message != "" {
+			fmt.Printf("OK: %s - %s\n", command, first.Message)
+		} else {
+			fmt.Printf("OK: %s %s\n", command, strings.TrimSpace(req.Program+" "+req.Signal))
+		}
+	}
+	return 0
+}
+
+// Wypisuje element strumienia jako tekst lub JSON
+func printStreamed(resp controlResponse, text string, jsonOut bool) {
+	if !jsonOut {
+		fmt.Println(text)
+		return
+	}
+	if resp.Event != nil {
+		printJSON(resp.Event)
+	} else {
+		printJSON(map[string]string{"line": resp.Line})
+	}
+}
+
+// Wypisuje wartość jako jedną linię JSON
+func printJSON(v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Println(string(data))
+}
+
+// Wypisuje stan programów jako tabelę lub JSON
+func printStatus(programs []ProgramStatus, jsonOut bool) {
+	if jsonOut {
+		data, _ := json.MarshalIndent(programs, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROGRAM\tSTAN\tPID\tCZAS DZIAŁANIA\tGOTOWY\tRESTARTY\tOSTATNI RESTART\tWYCIEK PAMIĘCI")
+	for _, p := range programs {
+		pid, uptime := "-", "-"
+		if p.PID != 0 {
+			pid = fmt.Sprint(p.PID)
+			uptime = (time.Duration(p.UptimeSeconds) * time.Second).String()
+		}
+		state := p.State
+		if p.Paused {
+			state += " (wstrzymany)"
+		}
+		last := "-"
+		if p.LastRestartAt != nil {
+			last = fmt.Sprintf("%s (%s)", p.LastRestartAt.Format("2006-01-02 15:04:05"), p.LastRestart)
+		}
+		leak := "-"
+		if p.MemoryCeilingAt != nil {
+			leak = fmt.Sprintf("%+.1f MB/h, limit %s", p.MemoryGrowth, p.MemoryCeilingAt.Format("01-02 15:04"))
+			if p.PlannedRestart != nil {
+				leak += ", restart " + p.PlannedRestart.Format("01-02 15:04")
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			p.Name, state, pid, uptime, yesNo(p.Ready), p.Restarts, last, leak)
+	}
+	w.Flush()
+}
+
+// Opis zdarzenia w jednej linii
+func formatEvent(e Event) string {
+	var b strings.Builder
+	b.WriteString(e.Time.Format("2006-01-02 15:04:05"))
+	if e.Program != "" {
+		fmt.Fprintf(&b, " [%s]", e.Program)
+	}
+	fmt.Fprintf(&b, " %s", e.Type)
+	if e.PID != 0 {
+		fmt.Fprintf(&b, " pid=%d", e.PID)
+	}
+	if e.ExitCode != nil {
+		fmt.Fprintf(&b, " kod=%d", *e.ExitCode)
+	}
+	if e.Signal != "" {
+		fmt.Fprintf(&b, " sygnał=%s", e.Signal)
+	}
+	if e.Probe != "" {
+		fmt.Fprintf(&b, " sonda=%q", e.Probe)
+	}
+	if e.Cause != "" {
+		fmt.Fprintf(&b, " przyczyna=%s", e.Cause)
+	}
+	if e.Uptime > 0 {
+		fmt.Fprintf(&b, " działał=%v", time.Duration(e.Uptime*float64(time.Second)).Round(time.Millisecond))
+	}
+	if e.Duration > 0 {
+		fmt.Fprintf(&b, " czas=%v", time.Duration(e.Duration*float64(time.Second)).Round(time.Millisecond))
+	}
+	if e.Reason != "" {
+		fmt.Fprintf(&b, " - %s", e.Reason)
+	}
+	return b.String()
+}
+
+// "tak" / "nie"
+func yesNo(v bool) string {
+	if v {
+		return "tak"
+	}
+	return "nie"
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// Polecenia operatora wykonywane w pętli nadzoru programu
+const (
+	actionStop    = "stop"
+	actionStart   = "start"
+	actionRestart = "restart"
+	actionPause   = "pause"
+	actionResume  = "resume"
+)
+
+// Żądanie operatora przekazywane do pętli nadzoru. Wykonanie w pętli
+// nadzoru wyklucza wyścig z oczekującym restartem.
+type monitorRequest struct {
+	action string     // Jedna ze stałych action*
+	reply  chan error // Wynik wykonania polecenia
+}
+
+// Stan programu udostępniany przez gniazdo sterujące
+type ProgramStatus struct {
+	Name            string     `json:"name"`                                // Nazwa programu
+	State           string     `json:"state"`                               // Aktualny stan
+	PID             int        `json:"pid,omitempty"`                       // PID bieżącego procesu
+	Ready           bool       `json:"ready"`                               // Czy sondy readiness się powiodły
+	Paused          bool       `json:"paused"`                              // Czy nadzór jest wstrzymany
+	UserStopped     bool       `json:"user_stopped"`                        // Zatrzymany na żądanie operatora
+	UptimeSeconds   float64    `json:"uptime_seconds"`                      // Czas działania bieżącego procesu
+	Restarts        int        `json:"restarts"`                            // Liczba restartów w historii
+	LastRestartAt   *time.Time `json:"last_restart_at,omitempty"`           // Czas ostatniego restartu
+	LastRestart     string     `json:"last_restart_reason,omitempty"`       // Powód ostatniego restartu
+	LastExit        string     `json:"last_exit,omitempty"`                 // Opis ostatniego zakończenia
+	StartupFailures int        `json:"startup_failures"`                    // Liczba nieudanych startów
+	MemoryGrowth    float64    `json:"memory_growth_mb_per_hour,omitempty"` // Przyrost RSS według trendu wycieku
+	MemoryCeilingAt *time.Time `json:"memory_ceiling_at,omitempty"`         // Przewidywane osiągnięcie limitu pamięci
+	PlannedRestart  *time.Time `json:"planned_restart_at,omitempty"`        // Restart zaplanowany z powodu wycieku
+}
+
+// Zwraca migawkę stanu programu
+func (m *Monitor) Status() ProgramStatus {
+	ready := m.Ready()
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	st := ProgramStatus{
+		Name:            m.name,
+		State:           m.state.String(),
+		Ready:           ready,
+		Paused:          m.paused,
+		UserStopped:     m.userStopped,
+		Restarts:        len(m.restarts),
+		StartupFailures: m.startupFailures,
+	}
+	if m.process != nil && m.process.Process != nil {
+		st.PID = m.process.Process.Pid
+		st.UptimeSeconds = time.Since(m.startedAt).Seconds()
+	}
+	if n := len(m.restarts); n > 0 {
+		last := m.restarts[n-1]
+		st.LastRestartAt = &last.at
+		st.LastRestart = last.reason
+	}
+	if m.startErr != nil {
+		st.LastExit = fmt.Sprintf("błąd uruchomienia: %v", m.startErr)
+	} else if m.lastExit != nil {
+		st.LastExit = m.lastExit.describe()
+	}
+	if plan := m.leakPlan; plan.rate > 0 {
+		st.MemoryGrowth = plan.rate * 3600 / (1 << 20)
+		st.MemoryCeilingAt = &plan.ceilingAt
+		if !plan.plannedAt.IsZero() {
+			st.PlannedRestart = &plan.plannedAt
+		}
+	}
+	return st
+}
+
+// Przekazuje polecenie do pętli nadzoru i czeka na jego wykonanie
+func (m *Monitor) request(action string) error {
+	req := monitorRequest{action: action, reply: make(chan error, 1)}
+	select {
+	case m.requests <- req:
+	case <-m.stopped:
+		return fmt.Errorf("nadzór programu %s został zakończony", m.name)
+	}
+	select {
+	case err := <-req.reply:
+		return err
+	case <-m.stopped:
+		return fmt.Errorf("nadzór programu %s został zakończony", m.name)
+	}
+}
+
+// Wykonuje polecenie operatora w pętli nadzoru. Zwraca nowy timer
+// opóźnionego restartu - każde polecenie anuluje oczekujący restart.
+func (m *Monitor) handleRequest(req monitorRequest, restartTimer <-chan time.Time) <-chan time.Time {
+	var err error
+	switch req.action {
+	case actionStop:
+		if m.isUserStopped() {
+			err = fmt.Errorf("program jest już zatrzymany")
+			break
+		}
+		m.Stop()
+		restartTimer = nil
+
+	case actionStart:
+		if m.isProcessRunning() {
+			err = fmt.Errorf("program już działa")
+			break
+		}
+		m.logf("Uruchamianie na żądanie operatora\n")
+		if err = m.Start(); err == nil {
+			restartTimer = nil
+		}
+
+	case actionRestart:
+		m.logf("Restartowanie procesu - powód: na żądanie operatora\n")
+		m.recordRestart(causeManual, "na żądanie operatora")
+		if err = m.Start(); err == nil {
+			restartTimer = nil
+		}
+
+	case actionPause:
+		m.mutex.Lock()
+		m.paused = true
+		m.mutex.Unlock()
+		m.logf("Nadzór wstrzymany - brak restartów z powodu logów i sond\n")
+
+	case actionResume:
+		m.mutex.Lock()
+		m.paused = false
+		m.lastModTime = time.Now()
+		m.mutex.Unlock()
+		m.logf("Nadzór wznowiony\n")
+
+	default:
+		err = fmt.Errorf("nieznane polecenie %q", req.action)
+	}
+
+	req.reply <- err
+	return restartTimer
+}
+
+// Czy nadzór programu jest wstrzymany
+func (m *Monitor) isPaused() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.paused
+}
+
+// Wstrzymany nadzór nadal czyta logi (żeby po wznowieniu nie analizować
+// zaległych linii), ale ignoruje ciszę, wzorce błędów i sondy
+func (m *Monitor) drainPaused() {
+	m.setLastActivity(time.Now())
+	if _, err := m.checkLogs(); err != nil {
+		return
+	}
+	m.takeErrorTrigger()
+	m.takeProbeFailure()
+}
+
+// Wysyła sygnał do całej grupy procesów programu
+func (m *Monitor) Signal(sig syscall.Signal) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.process == nil || m.process.Process == nil || m.exit.exited() {
+		return fmt.Errorf("proces nie działa")
+	}
+	pid := m.process.Process.Pid
+	m.logf("Wysyłanie %s do grupy procesów %d na żądanie operatora\n", signalName(sig), pid)
+	return syscall.Kill(-pid, sig)
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Obsługiwana wersja schematu pliku konfiguracyjnego
+const configVersion = 1
+
+// Domyślne wartości ustawień programu
+const (
+	defaultTimeout   = 60 * time.Second
+	defaultInterval  = 5 * time.Second
+	defaultKillGrace = 5 * time.Second
+)
+
+// Duration akceptuje w JSON liczbę sekund (60) lub tekst ("1m30s")
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("nieprawidłowy czas %q: %v", s, err)
+		}
+		d.Duration = parsed
+		return nil
+	}
+
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("nieprawidłowy czas %s: oczekiwano liczby sekund lub tekstu np. \"30s\"", data)
+	}
+	d.Duration = time.Duration(seconds * float64(time.Second))
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Konfiguracja pojedynczego nadzorowanego programu
+type ProgramConfig struct {
+	Name       string            `json:"name"`        // Unikalna nazwa programu
+	Command    string            `json:"command"`     // Komenda powłoki (wyklucza się z args)
+	Args       []string          `json:"args"`        // Program i argumenty uruchamiane bez powłoki
+	Shell      string            `json:"shell"`       // Interpreter komendy powłoki (domyślnie sh), np. "bash"
+	LogFile    string            `json:"log_file"`    // Ścieżka do pliku logów
+	Timeout    Duration          `json:"timeout"`     // Jak długo czekać bez zmian w logach
+	Interval   Duration          `json:"interval"`    // Jak często sprawdzać
+	KillGrace  Duration          `json:"kill_grace"`  // Czas między SIGTERM a SIGKILL
+	Env        map[string]string `json:"env"`         // Dodatkowe zmienne środowiskowe (z rozwijaniem $VAR)
+	EnvFile    string            `json:"env_file"`    // Plik zmiennych w formacie dotenv
+	ClearEnv   bool              `json:"clear_env"`   // Bez dziedziczenia środowiska monitora
+	WorkingDir string            `json:"working_dir"` // Katalog roboczy procesu
+	Umask      string            `json:"umask"`       // Umask procesu zapisany ósemkowo, np. "0027"
+	User       string            `json:"user"`        // Użytkownik procesu (wymaga roota)
+	Group      string            `json:"group"`       // Grupa procesu (domyślnie główna grupa użytkownika)
+	NewSession bool              `json:"new_session"` // Uruchamianie w nowej sesji (setsid) zamiast grupy procesów
+
+	HeartbeatPatterns []string `json:"heartbeat_patterns"` // Linie uznawane za aktywność
+	IgnorePatterns    []string `json:"ignore_patterns"`    // Linie nigdy nie uznawane za aktywność
+
+	ErrorPatterns []ErrorPatternConfig `json:"error_patterns"` // Wzorce błędów wyzwalające restart
+
+	Output  OutputConfig  `json:"output"`  // Przechwytywanie stdout/stderr procesu
+	Restart RestartConfig `json:"restart"` // Polityka restartów
+	Stop    StopConfig    `json:"stop"`    // Sekwencja zatrzymania
+	Hooks   HooksConfig   `json:"hooks"`   // Hooki cyklu życia
+
+	Diagnostics DiagnosticsConfig `json:"diagnostics"` // Diagnostyka przed zabiciem zawieszonego procesu
+	Cgroup      *CgroupConfig     `json:"cgroup"`      // Osobna cgroup v2 z limitami zasobów (nil = bez cgroup)
+	Watchdog    WatchdogConfig    `json:"watchdog"`    // Strażnik zasobów: RSS, CPU, deskryptory i wątki
+	Leak        LeakConfig        `json:"leak"`        // Wykrywanie wycieku pamięci i planowany restart
+
+	Startup StartupConfig `json:"startup"` // Faza startu przed kontrolą aktywności
+	Probes  []ProbeConfig `json:"probes"`  // Aktywne sondy liveness, readiness i startup
+}
+
+// Uzupełnia brakujące ustawienia wartościami domyślnymi
+func (p *ProgramConfig) applyDefaults() {
+	if p.Timeout.Duration == 0 {
+		p.Timeout.Duration = defaultTimeout
+	}
+	if p.Interval.Duration == 0 {
+		p.Interval.Duration = defaultInterval
+	}
+	if p.KillGrace.Duration == 0 {
+		p.KillGrace.Duration = defaultKillGrace
+	}
+	p.Output.applyDefaults()
+	p.Restart.applyDefaults()
+	p.Stop.applyDefaults()
+	p.Hooks.applyDefaults()
+	p.Diagnostics.applyDefaults()
+	p.Watchdog.applyDefaults()
+	p.Leak.applyDefaults()
+	for i := range p.Probes {
+		p.Probes[i].applyDefaults()
+	}
+	p.Startup.applyDefaults(p.Probes)
+}
+
+// Sprawdza poprawność ustawień programu
+func (p *ProgramConfig) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("brak nazwy programu")
+	}
+	// Nazwa jest częścią ścieżek cgroup i katalogu diagnostyki
+	if strings.Contains(p.Name, "/") || p.Name == "." || p.Name == ".." {
+		return fmt.Errorf("nieprawidłowa nazwa programu %q (nie może zawierać \"/\" ani być \".\" lub \"..\")", p.Name)
+	}
+	if p.Command == "" && len(p.Args) == 0 {
+		return fmt.Errorf("brak komendy (command lub args)")
+	}
+	if p.Command != "" && len(p.Args) > 0 {
+		return fmt.Errorf("command i args wykluczają się - command uruchamia powłokę, args program bezpośrednio")
+	}
+	if len(p.Args) > 0 && p.Args[0] == "" {
+		return fmt.Errorf("args: pusta nazwa programu")
+	}
+	if p.Shell != "" && p.Command == "" {
+		return fmt.Errorf("shell dotyczy tylko command")
+	}
+	if p.LogFile == "" {
+		return fmt.Errorf("brak pliku logów")
+	}
+	if p.Timeout.Duration <= 0 || p.Interval.Duration <= 0 {
+		return fmt.Errorf("timeout i interwał muszą być dodatnie")
+	}
+	if p.KillGrace.Duration < 0 {
+		return fmt.Errorf("kill_grace nie może być ujemny")
+	}
+	if p.WorkingDir != "" {
+		info, err := os.Stat(p.WorkingDir)
+		if err != nil {
+			return fmt.Errorf("katalog roboczy: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("katalog roboczy %s nie jest katalogiem", p.WorkingDir)
+		}
+	}
+	if p.EnvFile != "" {
+		var e environ
+		if err := e.loadFile(p.EnvFile); err != nil {
+			return fmt.Errorf("env_file: %v", err)
+		}
+	}
+	if _, err := parseUmask(p.Umask); err != nil {
+		return err
+	}
+	if _, _, err := resolveCredential(p.User, p.Group); err != nil {
+		return err
+	}
+	if _, err := newLogMatcher(*p); err != nil {
+		return err
+	}
+	if err := p.Output.validate(); err != nil {
+		return err
+	}
+	if err := p.Restart.validate(); err != nil {
+		return err
+	}
+	if err := p.Stop.validate(); err != nil {
+		return err
+	}
+	if err := p.Hooks.validate(); err != nil {
+		return err
+	}
+	if err := p.Diagnostics.validate(); err != nil {
+		return err
+	}
+	if p.Cgroup != nil {
+		if err := p.Cgroup.validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.Watchdog.validate(); err != nil {
+		return err
+	}
+	if err := p.Leak.validate(); err != nil {
+		return err
+	}
+	if err := p.Startup.validate(); err != nil {
+		return err
+	}
+	for i := range p.Probes {
+		if err := p.Probes[i].validate(); err != nil {
+			return fmt.Errorf("sonda %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Zawartość pliku konfiguracyjnego
+type Config struct {
+	Version   int              `json:"version"`   // Wersja schematu
+	Programs  []ProgramConfig  `json:"programs"`  // Nadzorowane programy
+	Control   ControlConfig    `json:"control"`   // Gniazdo sterujące
+	Metrics   MetricsConfig    `json:"metrics"`   // Endpoint metryk Prometheus
+	Events    EventsConfig     `json:"events"`    // Dziennik zdarzeń JSON lines
+	Notifiers []NotifierConfig `json:"notifiers"` // Powiadomienia o zdarzeniach
+}
+
+// Wczytuje, uzupełnia i waliduje plik konfiguracyjny JSON
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("nie można odczytać konfiguracji: %v", err)
+	}
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", path, err)
+	}
+	return cfg, nil
+}
+
+// Parsuje konfigurację zapamiętując linie, w których zaczynają się programy,
+// aby błędy walidacji wskazywały miejsce w pliku
+func parseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	var programLines, notifierLines []int
+	controlLine, metricsLine := 1, 1
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := expectDelim(dec, data, '{'); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		keyOffset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, jsonError(data, dec, err)
+		}
+		key, _ := tok.(string)
+
+		switch key {
+		case "version":
+			start := dec.InputOffset()
+			if err := dec.Decode(&cfg.Version); err != nil {
+				return nil, fmt.Errorf("%d: %v", errorLine(data, err, start), jsonErrorText(err))
+			}
+		case "programs":
+			if err := expectDelim(dec, data, '['); err != nil {
+				return nil, err
+			}
+			for dec.More() {
+				start := dec.InputOffset()
+				line := lineAt(data, start)
+				var p ProgramConfig
+				if err := dec.Decode(&p); err != nil {
+					return nil, fmt.Errorf("%d: program #%d: %v", errorLine(data, err, start), len(cfg.Programs)+1, jsonErrorText(err))
+				}
+				cfg.Programs = append(cfg.Programs, p)
+				programLines = append(programLines, line)
+			}
+			if err := expectDelim(dec, data, ']'); err != nil {
+				return nil, err
+			}
+		case "control":
+			start := dec.InputOffset()
+			controlLine = lineAt(data, start)
+			if err := dec.Decode(&cfg.Control); err != nil {
+				return nil, fmt.Errorf("%d: control: %v", errorLine(data, err, start), jsonErrorText(err))
+			}
+		case "events":
+			start := dec.InputOffset()
+			if err := dec.Decode(&cfg.Events); err != nil {
+				return nil, fmt.Errorf("%d: events: %v", errorLine(data, err, start), jsonErrorText(err))
+			}
+		case "notifiers":
+			if err := expectDelim(dec, data, '['); err != nil {
+				return nil, err
+			}
+			for dec.More() {
+				start := dec.InputOffset()
+				line := lineAt(data, start)
+				var n NotifierConfig
+				if err := dec.Decode(&n); err != nil {
+					return nil, fmt.Errorf("%d: notifier #%d: %v", errorLine(data, err, start), len(cfg.Notifiers)+1, jsonErrorText(err))
+				}
+				cfg.Notifiers = append(cfg.Notifiers, n)
+				notifierLines = append(notifierLines, line)
+			}
+			if err := expectDelim(dec, data, ']'); err != nil {
+				return nil, err
+			}
+		case "metrics":
+			start := dec.InputOffset()
+			metricsLine = lineAt(data, start)
+			if err := dec.Decode(&cfg.Metrics); err != nil {
+				return nil, fmt.Errorf("%d: metrics: %v", errorLine(data, err, start), jsonErrorText(err))
+			}
+		default:
+			return nil, fmt.Errorf("%d: nieznane pole %q", lineAt(data, keyOffset), key)
+		}
+	}
+	if err := expectDelim(dec, data, '}'); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%d: nadmiarowe dane po końcu konfiguracji", lineAt(data, dec.InputOffset()))
+	}
+
+	// Walidacja schematu
+	if cfg.Version == 0 {
+		return nil, fmt.Errorf("1: brak pola \"version\" (obsługiwana wersja: %d)", configVersion)
+	}
+	if cfg.Version != configVersion {
+		return nil, fmt.Errorf("1: nieobsługiwana wersja konfiguracji %d (obsługiwana: %d)", cfg.Version, configVersion)
+	}
+	if len(cfg.Programs) == 0 {
+		return nil, fmt.Errorf("1: brak programów do nadzorowania")
+	}
+
+	names := make(map[string]int)
+	for i := range cfg.Programs {
+		p := &cfg.Programs[i]
+		p.applyDefaults()
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%d: program %q: %v", programLines[i], p.Name, err)
+		}
+		if first, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("%d: program %q: nazwa użyta już w linii %d", programLines[i], p.Name, first)
+		}
+		names[p.Name] = programLines[i]
+	}
+
+	cfg.Control.applyDefaults()
+	if err := cfg.Control.validate(); err != nil {
+		return nil, fmt.Errorf("%d: %v", controlLine, err)
+	}
+	cfg.Metrics.applyDefaults()
+	if err := cfg.Metrics.validate(); err != nil {
+		return nil, fmt.Errorf("%d: %v", metricsLine, err)
+	}
+	for i := range cfg.Notifiers {
+		n := &cfg.Notifiers[i]
+		n.applyDefaults()
+		if err := n.validate(); err != nil {
+			return nil, fmt.Errorf("%d: notifier %q: %v", notifierLines[i], n.Name, err)
+		}
+		for _, p := range n.Programs {
+			if _, ok := names[p]; !ok {
+				return nil, fmt.Errorf("%d: notifier %q: nieznany program %q", notifierLines[i], n.Name, p)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// Odczytuje kolejny token i sprawdza czy jest oczekiwanym nawiasem
+func expectDelim(dec *json.Decoder, data []byte, want json.Delim) error {
+	offset := dec.InputOffset()
+	tok, err := dec.Token()
+	if err != nil {
+		return jsonError(data, dec, err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("%d: oczekiwano %q, znaleziono %v", lineAt(data, offset), string(want), tok)
+	}
+	return nil
+}
+
+// Zamienia błąd dekodera JSON na komunikat z numerem linii
+func jsonError(data []byte, dec *json.Decoder, err error) error {
+	line := errorLine(data, err, dec.InputOffset())
+	return fmt.Errorf("%d: %v", line, jsonErrorText(err))
+}
+
+// Zwraca linię wskazaną przez błąd JSON dekodowania wartości zaczynającej
+// się od przesunięcia start, a gdy błąd nie niesie położenia - linię
+// początku wartości. Przesunięcie błędu składni liczone jest od początku
+// danych, a błędu typu - od początku dekodowanej wartości.
+func errorLine(data []byte, err error, start int64) int {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return lineOf(data, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return lineOf(data, valueStart(data, start)+typeErr.Offset)
+	}
+	return lineAt(data, start)
+}
+
+// Numer linii (od 1) dla przesunięcia w danych
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// Początek bufora wartości w dekoderze: za przecinkiem lub dwukropkiem
+// poprzedzającym wartość, jeśli taki jest
+func valueStart(data []byte, offset int64) int64 {
+	for i := offset; i < int64(len(data)); i++ {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case ',', ':':
+			return i + 1
+		}
+		break
+	}
+	return offset
+}
+
+// Upraszcza komunikaty błędów pakietu encoding/json
+func jsonErrorText(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Sprintf("pole %q: oczekiwano typu %v", typeErr.Field, typeErr.Type)
+	}
+	var syntaxErr *json.SyntaxError
+	if err == io.ErrUnexpectedEOF || err == io.EOF ||
+		errors.As(err, &syntaxErr) && syntaxErr.Error() == "unexpected end of JSON input" {
+		return "nieoczekiwany koniec pliku"
+	}
+	if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+		return "nieznane pole " + field
+	}
+	return err.Error()
+}
+
+// Zwraca numer linii (od 1) dla podanego przesunięcia w danych,
+// pomijając białe znaki i przecinki przed właściwym elementem
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	for offset < int64(len(data)) {
+		c := data[offset]
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != ',' && c != ':' {
+			break
+		}
+		offset++
+	}
+	return lineOf(data, offset)
+}
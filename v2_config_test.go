@@ -0,0 +1,172 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigErrorLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		line   int
+		text   string // Fragment komunikatu
+	}{
+		{
+			name:   "składnia w pierwszej linii",
+			config: `{"version": 1,, "programs": []}`,
+			line:   1,
+			text:   "invalid character",
+		},
+		{
+			name: "składnia w środku",
+			config: `{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log"}
+    {"name": "b", "command": "sleep 1", "log_file": "/tmp/b.log"}
+  ]
+}`,
+			line: 5,
+			text: "invalid character",
+		},
+		{
+			name: "brak przecinka między polami programu",
+			config: `{
+  "version": 1,
+  "programs": [
+    {
+      "name": "a",
+      "command": "sleep 1"
+      "log_file": "/tmp/a.log"
+    }
+  ]
+}`,
+			line: 7,
+			text: "invalid character",
+		},
+		{
+			name: "niezamknięty obiekt w ostatniej linii",
+			config: `{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log"}
+  ]`,
+			line: 5,
+			text: "nieoczekiwany koniec pliku",
+		},
+		{
+			name: "nadmiarowe dane w ostatniej linii",
+			config: `{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log"}
+  ]
+} {}`,
+			line: 6,
+			text: "nadmiarowe dane",
+		},
+		{
+			name:   "typ w pierwszej linii",
+			config: `{"version": "1", "programs": []}`,
+			line:   1,
+			text:   "oczekiwano typu int",
+		},
+		{
+			name: "typ w środku",
+			config: `{
+  "version": 1,
+  "programs": [
+    {
+      "name": "a",
+      "args": "sleep 1",
+      "log_file": "/tmp/a.log"
+    }
+  ]
+}`,
+			line: 6,
+			text: `pole "args": oczekiwano typu []string`,
+		},
+		{
+			name: "typ w ostatniej linii",
+			config: `{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log"}
+  ],
+  "metrics": {"listen": 9100}}`,
+			line: 6,
+			text: `pole "listen": oczekiwano typu string`,
+		},
+		{
+			name: "nieznane pole",
+			config: `{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log"}
+  ],
+  "metric": {}
+}`,
+			line: 6,
+			text: `nieznane pole "metric"`,
+		},
+		{
+			name: "walidacja wskazuje początek programu",
+			config: `{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log"},
+    {
+      "name": "b",
+      "command": "sleep 1"
+    }
+  ]
+}`,
+			line: 5,
+			text: `program "b": brak pliku logów`,
+		},
+		{
+			name: "powtórzona nazwa",
+			config: `{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log"},
+    {"name": "a", "command": "sleep 2", "log_file": "/tmp/b.log"}
+  ]
+}`,
+			line: 5,
+			text: "nazwa użyta już w linii 4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(tt.config))
+			if err == nil {
+				t.Fatal("oczekiwano błędu")
+			}
+			line, text, _ := strings.Cut(err.Error(), ": ")
+			if line != strconv.Itoa(tt.line) {
+				t.Errorf("błąd w linii %s, oczekiwano %d: %v", line, tt.line, err)
+			}
+			if !strings.Contains(text, tt.text) {
+				t.Errorf("komunikat %q nie zawiera %q", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestParseConfigValid(t *testing.T) {
+	cfg, err := parseConfig([]byte(`{
+  "version": 1,
+  "programs": [
+    {"name": "a", "command": "sleep 1", "log_file": "/tmp/a.log", "timeout": "2m"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Programs) != 1 || cfg.Programs[0].Timeout.Duration.String() != "2m0s" {
+		t.Errorf("programy: %+v", cfg.Programs)
+	}
+}
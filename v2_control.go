@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Domyślne uprawnienia gniazda sterującego - tylko właściciel
+const defaultControlMode = "0600"
+
+// Maksymalna długość jednej linii żądania
+const maxControlLine = 64 << 10
+
+// Konfiguracja gniazda sterującego (Unix domain socket)
+type ControlConfig struct {
+	Socket   string `json:"socket"`   // Ścieżka gniazda (domyślnie w XDG_RUNTIME_DIR lub /tmp)
+	Mode     string `json:"mode"`     // Uprawnienia pliku gniazda, ósemkowo (domyślnie 0600)
+	Group    string `json:"group"`    // Grupa właściciela gniazda (nazwa lub GID)
+	Disabled bool   `json:"disabled"` // Wyłącza gniazdo sterujące
+}
+
+// Domyślna ścieżka gniazda sterującego dla bieżącego użytkownika
+func defaultControlSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "monitor.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("monitor-%d.sock", os.Getuid()))
+}
+
+// Uzupełnia brakujące ustawienia gniazda
+func (c *ControlConfig) applyDefaults() {
+	if c.Socket == "" {
+		c.Socket = defaultControlSocket()
+	}
+	if c.Mode == "" {
+		c.Mode = defaultControlMode
+	}
+}
+
+// Sprawdza poprawność ustawień gniazda
+func (c *ControlConfig) validate() error {
+	if _, err := c.fileMode(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Zwraca uprawnienia pliku gniazda
+func (c *ControlConfig) fileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(c.Mode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("control: nieprawidłowe uprawnienia %q (oczekiwano np. \"0660\")", c.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Żądanie przesyłane przez gniazdo - jeden obiekt JSON na linię
+// lub tekst "polecenie [program] [sygnał]"
+type controlRequest struct {
+	Command string `json:"command"` // status, stop, start, restart, signal, pause, resume, reload, logs, events
+	Program string `json:"program"` // Nazwa programu (dla status opcjonalna)
+	Signal  string `json:"signal"`  // Sygnał dla polecenia signal, np. "HUP"
+	Follow  bool   `json:"follow"`  // logs: śledzenie nowych linii
+	Lines   int    `json:"lines"`   // logs: liczba ostatnich linii (domyślnie 10)
+}
+
+// Odpowiedź na żądanie - jeden obiekt JSON na linię. Polecenia logs
+// i events przejmują połączenie i wysyłają kolejne odpowiedzi z liniami
+// lub zdarzeniami aż do rozłączenia klienta (logs bez śledzenia - do końca pliku).
+type controlResponse struct {
+	OK       bool            `json:"ok"`                 // Czy polecenie się powiodło
+	Error    string          `json:"error,omitempty"`    // Opis błędu
+	Programs []ProgramStatus `json:"programs,omitempty"` // Stan programów (status)
+	Line     string          `json:"line,omitempty"`     // Linia logu (logs)
+	Event    *Event          `json:"event,omitempty"`    // Zdarzenie (events)
+	Message  string          `json:"message,omitempty"`  // Podsumowanie zmian (reload)
+}
+
+// Parsuje linię żądania w formacie JSON lub tekstowym
+func parseControlRequest(line string) (controlRequest, error) {
+	var req controlRequest
+	if strings.HasPrefix(line, "{") {
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			return req, fmt.Errorf("nieprawidłowe żądanie: %v", err)
+		}
+		return req, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		req.Command = fields[0]
+	}
+	if len(fields) > 1 {
+		req.Program = fields[1]
+	}
+	if len(fields) > 2 {
+		req.Signal = fields[2]
+	}
+	if len(fields) > 3 {
+		return req, fmt.Errorf("za dużo argumentów")
+	}
+	return req, nil
+}
+
+// Serwer gniazda sterującego supervisora
+type controlServer struct {
+	supervisor *Supervisor
+	listener   net.Listener
+	path       string
+}
+
+// Tworzy gniazdo sterujące z uprawnieniami z konfiguracji
+func listenControl(s *Supervisor, cfg ControlConfig) (*controlServer, error) {
+	mode, err := cfg.fileMode()
+	if err != nil {
+		return nil, err
+	}
+
+	// Pozostałość po poprzednim uruchomieniu usuwamy, ale tylko gdy
+	// nikt już nie nasłuchuje na gnieździe
+	if _, err := os.Lstat(cfg.Socket); err == nil {
+		if conn, err := net.DialTimeout("unix", cfg.Socket, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("gniazdo %s jest używane przez inny monitor", cfg.Socket)
+		}
+		if err := os.Remove(cfg.Socket); err != nil {
+			return nil, fmt.Errorf("nie można usunąć starego gniazda: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Socket), 0o755); err != nil {
+		return nil, fmt.Errorf("nie można utworzyć katalogu gniazda: %v", err)
+	}
+
+	// Gniazdo powstaje od razu z uprawnieniami tylko dla właściciela,
+	// docelowe są ustawiane po ewentualnej zmianie grupy. Umask procesu
+	// zmienia też startWithUmask, stąd wspólna blokada.
+	umaskMutex.Lock()
+	oldMask := syscall.Umask(0o177)
+	listener, err := net.Listen("unix", cfg.Socket)
+	syscall.Umask(oldMask)
+	umaskMutex.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("nie można utworzyć gniazda: %v", err)
+	}
+
+	if cfg.Group != "" {
+		gid, err := lookupGroup(cfg.Group)
+		if err == nil {
+			err = os.Chown(cfg.Socket, -1, gid)
+		}
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("nie można ustawić grupy gniazda: %v", err)
+		}
+	}
+	if err := os.Chmod(cfg.Socket, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("nie można ustawić uprawnień gniazda: %v", err)
+	}
+
+	return &controlServer{supervisor: s, listener: listener, path: cfg.Socket}, nil
+}
+
+// Zamienia nazwę grupy lub GID na numer
+func lookupGroup(name string) (int, error) {
+	if gid, err := strconv.Atoi(name); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// Przyjmuje połączenia do zamknięcia gniazda
+func (c *controlServer) serve() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return
+		}
+		go c.handleConn(conn)
+	}
+}
+
+// Zamyka gniazdo (plik gniazda jest usuwany)
+func (c *controlServer) close() {
+	c.listener.Close()
+}
+
+// Obsługuje jedno połączenie - wiele żądań, każde w osobnej linii
+func (c *controlServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxControlLine)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		resp := controlResponse{OK: true}
+		req, err := parseControlRequest(line)
+		if err == nil && (req.Command == "logs" || req.Command == "events") {
+			c.stream(conn, enc, req)
+			return
+		}
+		if err == nil && req.Command == "reload" {
+			resp.Message, err = c.supervisor.Reload()
+		} else if err == nil {
+			resp.Programs, err = c.execute(req)
+		}
+		if err != nil {
+			resp = controlResponse{Error: err.Error()}
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// Obsługuje polecenia strumieniowe - połączenie jest zamykane po ich końcu
+func (c *controlServer) stream(conn net.Conn, enc *json.Encoder, req controlRequest) {
+	if req.Command == "events" {
+		if req.Program != "" && c.supervisor.Monitor(req.Program) == nil {
+			enc.Encode(controlResponse{Error: fmt.Sprintf("nieznany program %q", req.Program)})
+			return
+		}
+		c.streamEvents(conn, enc, req)
+		return
+	}
+
+	if req.Program == "" {
+		enc.Encode(controlResponse{Error: "polecenie logs wymaga nazwy programu"})
+		return
+	}
+	m := c.supervisor.Monitor(req.Program)
+	if m == nil {
+		enc.Encode(controlResponse{Error: fmt.Sprintf("nieznany program %q", req.Program)})
+		return
+	}
+	c.streamLogs(conn, enc, m, req)
+}
+
+// Wykonuje żądanie i zwraca stan programów (dla status)
+func (c *controlServer) execute(req controlRequest) ([]ProgramStatus, error) {
+	s := c.supervisor
+
+	switch req.Command {
+	case "status":
+		return s.Status(req.Program)
+	case "":
+		return nil, fmt.Errorf("brak polecenia")
+	}
+
+	if req.Program == "" {
+		return nil, fmt.Errorf("polecenie %s wymaga nazwy programu", req.Command)
+	}
+	m := s.Monitor(req.Program)
+	if m == nil {
+		return nil, fmt.Errorf("nieznany program %q", req.Program)
+	}
+
+	switch req.Command {
+	case "signal":
+		if req.Signal == "" {
+			return nil, fmt.Errorf("polecenie signal wymaga nazwy sygnału")
+		}
+		sig, err := parseSignal(req.Signal)
+		if err != nil {
+			return nil, err
+		}
+		return nil, m.Signal(sig)
+	case actionStop, actionStart, actionRestart, actionPause, actionResume:
+		m.logf("Polecenie z gniazda sterującego: %s\n", req.Command)
+		return nil, m.request(req.Command)
+	default:
+		return nil, fmt.Errorf("nieznane polecenie %q", req.Command)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Domyślne ustawienia zbierania diagnostyki
+const (
+	defaultDiagSignalWait = 2 * time.Second
+	defaultDiagTimeout    = 60 * time.Second
+	defaultDiagLogTailKB  = 64
+	defaultDiagKeep       = 10
+)
+
+// Przyczyny restartu oznaczające zawieszony (ale działający) proces
+var hangCauses = []string{causeLogTimeout, causeErrorPattern, causeProbe, causeStartup, causeResource}
+
+// Pliki z /proc/<pid> zapisywane dla każdego procesu z drzewa
+var diagProcFiles = []string{"cmdline", "status", "stack", "wchan"}
+
+// Zbieranie diagnostyki zawieszonego procesu przed jego zatrzymaniem
+type DiagnosticsConfig struct {
+	Dir        string   `json:"dir"`         // Katalog zrzutów (pusty = wyłączone); zrzuty w <dir>/<program>/<czas>
+	Signal     string   `json:"signal"`      // Sygnał wysyłany najpierw do procesu, np. SIGQUIT (zrzut wątków JVM)
+	SignalWait Duration `json:"signal_wait"` // Czas na zapis zrzutu po sygnale (domyślnie 2s)
+	Proc       bool     `json:"proc"`        // Zapis /proc/<pid>/status, stack, wchan, cmdline i listy fd drzewa procesów
+	Gcore      bool     `json:"gcore"`       // Zrzut pamięci programem gcore
+	Command    string   `json:"command"`     // Własna komenda (MONITOR_DIAG_DIR w środowisku)
+	Timeout    Duration `json:"timeout"`     // Limit czasu gcore i komendy (domyślnie 60s)
+	LogTailKB  int      `json:"log_tail_kb"` // Ile KB końca pliku logów zapisać (domyślnie 64)
+	Causes     []string `json:"causes"`      // Przyczyny restartu, przy których zbierać (domyślnie log_timeout)
+	Keep       int      `json:"keep"`        // Ile ostatnich zrzutów programu zachować (domyślnie 10)
+}
+
+// Uzupełnia brakujące ustawienia diagnostyki
+func (d *DiagnosticsConfig) applyDefaults() {
+	if d.SignalWait.Duration == 0 {
+		d.SignalWait.Duration = defaultDiagSignalWait
+	}
+	if d.Timeout.Duration == 0 {
+		d.Timeout.Duration = defaultDiagTimeout
+	}
+	if d.LogTailKB == 0 {
+		d.LogTailKB = defaultDiagLogTailKB
+	}
+	if len(d.Causes) == 0 {
+		d.Causes = []string{causeLogTimeout}
+	}
+	if d.Keep == 0 {
+		d.Keep = defaultDiagKeep
+	}
+}
+
+// Sprawdza poprawność ustawień diagnostyki
+func (d *DiagnosticsConfig) validate() error {
+	if d.Signal != "" {
+		if _, err := parseSignal(d.Signal); err != nil {
+			return fmt.Errorf("diagnostics: %v", err)
+		}
+	}
+	for _, c := range d.Causes {
+		if !containsString(hangCauses, c) {
+			return fmt.Errorf("diagnostics: nieznana przyczyna %q (dozwolone: %s)", c, strings.Join(hangCauses, ", "))
+		}
+	}
+	if d.SignalWait.Duration < 0 || d.Timeout.Duration <= 0 || d.LogTailKB < 0 || d.Keep < 1 {
+		return fmt.Errorf("diagnostics: signal_wait, timeout, log_tail_kb i keep muszą być dodatnie")
+	}
+	return nil
+}
+
+// Zbiera diagnostykę zawieszonego procesu, dopóki jeszcze działa.
+// Zwraca katalog zrzutu lub pusty tekst, gdy nic nie zebrano.
+func (m *Monitor) captureDiagnostics(cause, reason string) string {
+	cfg := m.diagCfg
+	if cfg.Dir == "" || !containsString(cfg.Causes, cause) {
+		return ""
+	}
+
+	m.mutex.RLock()
+	pid := m.pidUnsafe()
+	m.mutex.RUnlock()
+	if pid == 0 {
+		return ""
+	}
+
+	base := filepath.Join(cfg.Dir, m.name)
+	dir := filepath.Join(base, time.Now().Format("20060102-150405.000"))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		m.logf("Nie można utworzyć katalogu diagnostyki: %v\n", err)
+		return ""
+	}
+	m.logf("Zbieranie diagnostyki PID %d do %s\n", pid, dir)
+
+	var summary bytes.Buffer
+	fmt.Fprintf(&summary, "program: %s\npid: %d\nczas: %s\nprzyczyna: %s\npowód: %s\n\n",
+		m.name, pid, time.Now().Format(time.RFC3339), cause, reason)
+	step := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(&summary, "%s: błąd: %v\n", name, err)
+			m.logf("Diagnostyka %s: %v\n", name, err)
+		} else {
+			fmt.Fprintf(&summary, "%s: OK\n", name)
+		}
+	}
+
+	// Sygnał (np. SIGQUIT) tylko do głównego procesu - reszta grupy mogłaby
+	// zakończyć się z domyślnej akcji sygnału
+	if cfg.Signal != "" {
+		sig, _ := parseSignal(cfg.Signal)
+		err := syscall.Kill(pid, sig)
+		if err == nil {
+			time.Sleep(cfg.SignalWait.Duration)
+		}
+		step("signal "+signalName(sig), err)
+	}
+	if cfg.Proc {
+		step("proc", captureProc(dir, processTree(pid)))
+	}
+	if cfg.Gcore {
+		step("gcore", m.runDiagCommand(dir, "gcore.txt", []string{"gcore", "-o", filepath.Join(dir, "core"), strconv.Itoa(pid)}, nil))
+	}
+	if cfg.Command != "" {
+		env := []string{
+			"MONITOR_DIAG_DIR=" + dir,
+			"MONITOR_PROGRAM=" + m.name,
+			"MONITOR_PID=" + strconv.Itoa(pid),
+			"MONITOR_CAUSE=" + cause,
+			"MONITOR_REASON=" + reason,
+		}
+		step("command", m.runDiagCommand(dir, "command.txt", []string{"sh", "-c", cfg.Command}, env))
+	}
+	if cfg.LogTailKB > 0 {
+		step("log", copyLogTail(m.logFile, filepath.Join(dir, "log-tail.txt"), int64(cfg.LogTailKB)<<10))
+	}
+
+	os.WriteFile(filepath.Join(dir, "summary.txt"), summary.Bytes(), 0o640)
+	pruneDiagnostics(base, cfg.Keep)
+	m.logf("Diagnostyka zapisana w %s\n", dir)
+	return dir
+}
+
+// Zapisuje wybrane pliki /proc oraz listę deskryptorów każdego procesu drzewa
+func captureProc(dir string, tree []procEntry) error {
+	var failed []string
+	for _, p := range tree {
+		pdir := filepath.Join(dir, "proc", strconv.Itoa(p.pid))
+		if err := os.MkdirAll(pdir, 0o750); err != nil {
+			return err
+		}
+		src := filepath.Join("/proc", strconv.Itoa(p.pid))
+		for _, name := range diagProcFiles {
+			data, err := os.ReadFile(filepath.Join(src, name))
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%d/%s", p.pid, name))
+				data = []byte(fmt.Sprintf("błąd odczytu: %v\n", err))
+			} else if name == "cmdline" {
+				data = append(bytes.ReplaceAll(bytes.TrimRight(data, "\x00"), []byte{0}, []byte{' '}), '\n')
+			}
+			os.WriteFile(filepath.Join(pdir, name), data, 0o640)
+		}
+
+		var fds bytes.Buffer
+		entries, err := os.ReadDir(filepath.Join(src, "fd"))
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%d/fd", p.pid))
+			fmt.Fprintf(&fds, "błąd odczytu: %v\n", err)
+		}
+		for _, e := range entries {
+			target, _ := os.Readlink(filepath.Join(src, "fd", e.Name()))
+			fmt.Fprintf(&fds, "%s -> %s\n", e.Name(), target)
+		}
+		os.WriteFile(filepath.Join(pdir, "fd"), fds.Bytes(), 0o640)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("nie odczytano: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Uruchamia komendę diagnostyczną, zapisując jej wyjście w katalogu zrzutu
+func (m *Monitor) runDiagCommand(dir, output string, args []string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.diagCfg.Timeout.Duration)
+	defer cancel()
+
+	cmdEnv, err := m.commandEnv(env...)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(filepath.Join(dir, output))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	cmd := groupCommand(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = cmdEnv
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("przekroczono limit czasu %v", m.diagCfg.Timeout.Duration)
+	}
+	return err
+}
+
+// Kopiuje ostatnie limit bajtów pliku logów
+func copyLogTail(logFile, dest string, limit int64) error {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > limit {
+		if _, err := f.Seek(info.Size()-limit, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, io.LimitReader(f, limit))
+	return err
+}
+
+// Usuwa najstarsze zrzuty programu ponad limit keep
+func pruneDiagnostics(base string, keep int) {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	sort.Strings(dirs)
+	for len(dirs) > keep {
+		os.RemoveAll(filepath.Join(base, dirs[0]))
+		dirs = dirs[1:]
+	}
+}
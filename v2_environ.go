@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Umask jest atrybutem całego procesu monitora - zmiana na czas
+// uruchomienia programu musi być wyłączna
+var umaskMutex sync.Mutex
+
+// Parsuje umask zapisany ósemkowo, np. "0027" (pusty = dziedziczony, -1)
+func parseUmask(s string) (int, error) {
+	if s == "" {
+		return -1, nil
+	}
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v > 0o777 {
+		return 0, fmt.Errorf("nieprawidłowy umask %q (oczekiwano ósemkowo, np. \"0027\")", s)
+	}
+	return int(v), nil
+}
+
+// Uruchamia proces z podanym umask (dziedziczonym przez proces potomny
+// przy fork). Pliki tworzone w tym czasie przez inne gorutyny monitora
+// mają jawne uprawnienia, które umask może jedynie zawęzić.
+func startWithUmask(start func() error, umask int) error {
+	if umask < 0 {
+		return start()
+	}
+	umaskMutex.Lock()
+	defer umaskMutex.Unlock()
+	old := syscall.Umask(umask)
+	defer syscall.Umask(old)
+	return start()
+}
+
+// Wyszukuje użytkownika po nazwie lub UID
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if _, unknown := err.(user.UnknownUserError); unknown {
+		if _, nerr := strconv.ParseUint(name, 10, 32); nerr == nil {
+			u, err = user.LookupId(name)
+		}
+	}
+	if err != nil {
+		if _, nerr := strconv.ParseUint(name, 10, 32); nerr == nil {
+			// Numeryczny UID bez wpisu w passwd (np. w kontenerze)
+			return &user.User{Uid: name, Gid: name, Username: name}, nil
+		}
+		return nil, fmt.Errorf("użytkownik %q nie istnieje", name)
+	}
+	return u, nil
+}
+
+// Tożsamość procesu programu: UID, GID i grupy dodatkowe użytkownika.
+// Zwraca nil, gdy proces działa jako monitor. Uprawnienia są zmieniane
+// w procesie potomnym przed exec (setgroups, setgid, setuid).
+func resolveCredential(userName, groupName string) (*syscall.Credential, *user.User, error) {
+	if userName == "" && groupName == "" {
+		return nil, nil, nil
+	}
+	cred := &syscall.Credential{Uid: uint32(os.Geteuid()), Gid: uint32(os.Getegid()), NoSetGroups: true}
+
+	var u *user.User
+	if userName != "" {
+		var err error
+		if u, err = lookupUser(userName); err != nil {
+			return nil, nil, err
+		}
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+		cred.Uid, cred.Gid = uint32(uid), uint32(gid)
+
+		// Grupy dodatkowe jak przy logowaniu (initgroups)
+		cred.NoSetGroups = false
+		cred.Groups = []uint32{}
+		if ids, err := u.GroupIds(); err == nil {
+			for _, id := range ids {
+				if gid, err := strconv.ParseUint(id, 10, 32); err == nil && uint32(gid) != cred.Gid {
+					cred.Groups = append(cred.Groups, uint32(gid))
+				}
+			}
+		}
+	}
+	if groupName != "" {
+		gid, err := lookupGroup(groupName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("grupa %q nie istnieje", groupName)
+		}
+		cred.Gid = uint32(gid)
+
+		// Sama grupa: bez grup dodatkowych monitora (np. roota)
+		if u == nil && os.Geteuid() == 0 {
+			cred.NoSetGroups = false
+			cred.Groups = []uint32{}
+		}
+	}
+
+	if os.Geteuid() != 0 && (cred.Uid != uint32(os.Geteuid()) || cred.Gid != uint32(os.Getegid())) {
+		return nil, nil, fmt.Errorf("user/group wymaga uruchomienia monitora jako root")
+	}
+	return cred, u, nil
+}
+
+// Środowisko budowane w kolejności ustawiania zmiennych
+type environ struct {
+	vars  []string       // KLUCZ=WARTOŚĆ
+	index map[string]int // Pozycja zmiennej w vars
+}
+
+// Ustawia zmienną (nadpisuje wcześniejszą wartość)
+func (e *environ) set(key, value string) {
+	if e.index == nil {
+		e.index = make(map[string]int)
+	}
+	if i, ok := e.index[key]; ok {
+		e.vars[i] = key + "=" + value
+		return
+	}
+	e.index[key] = len(e.vars)
+	e.vars = append(e.vars, key+"="+value)
+}
+
+// Wartość zmiennej
+func (e *environ) get(key string) (string, bool) {
+	i, ok := e.index[key]
+	if !ok {
+		return "", false
+	}
+	return e.vars[i][len(key)+1:], true
+}
+
+// Rozwija $VAR, ${VAR} i ${VAR:-domyślna} na podstawie zmiennych już
+// ustawionych; $$ oznacza znak $
+func (e *environ) expand(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		key, def, hasDef := strings.Cut(name, ":-")
+		if v, ok := e.get(key); ok && (v != "" || !hasDef) {
+			return v
+		}
+		return def
+	})
+}
+
+// Wczytuje plik w formacie dotenv: KLUCZ=WARTOŚĆ, opcjonalny prefiks
+// export, komentarze #, wartości w cudzysłowach. Wartości bez cudzysłowów
+// i w cudzysłowach podwójnych są rozwijane; w pojedynczych - dosłowne.
+func (e *environ) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("%s:%d: oczekiwano KLUCZ=WARTOŚĆ", path, lineNo)
+		}
+		value = strings.TrimSpace(value)
+
+		quoted, ok := cutQuoted(value)
+		switch {
+		case ok && value[0] == '\'':
+			value = quoted
+		case ok:
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(quoted)
+			value = e.expand(value)
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			value = e.expand(value)
+		}
+		e.set(key, value)
+	}
+	return scanner.Err()
+}
+
+// Zwraca treść wartości w cudzysłowach pojedynczych lub podwójnych, po
+// której może wystąpić już tylko komentarz #. W podwójnych \" nie zamyka
+// wartości.
+func cutQuoted(value string) (string, bool) {
+	if len(value) < 2 || (value[0] != '\'' && value[0] != '"') {
+		return "", false
+	}
+	q := value[0]
+	for i := 1; i < len(value); i++ {
+		switch {
+		case q == '"' && value[i] == '\\':
+			i++
+		case value[i] == q:
+			rest := strings.TrimSpace(value[i+1:])
+			if rest != "" && rest[0] != '#' {
+				return "", false
+			}
+			return value[1:i], true
+		}
+	}
+	return "", false
+}
+
+// Buduje środowisko procesu programu: środowisko monitora (chyba że
+// clear_env), HOME/USER/LOGNAME docelowego użytkownika, env_file i env.
+// Plik env_file jest czytany przy każdym starcie.
+func (m *Monitor) processEnv(u *user.User) ([]string, error) {
+	e := environ{vars: []string{}}
+	if !m.clearEnv {
+		for _, kv := range os.Environ() {
+			if key, value, ok := strings.Cut(kv, "="); ok {
+				e.set(key, value)
+			}
+		}
+	}
+	if u != nil {
+		if u.HomeDir != "" {
+			e.set("HOME", u.HomeDir)
+		}
+		e.set("USER", u.Username)
+		e.set("LOGNAME", u.Username)
+	}
+	if m.envFile != "" {
+		if err := e.loadFile(m.envFile); err != nil {
+			return nil, fmt.Errorf("env_file: %v", err)
+		}
+	}
+
+	keys := make([]string, 0, len(m.config.Env))
+	for k := range m.config.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.set(k, e.expand(m.config.Env[k]))
+	}
+	return e.vars, nil
+}
+
+// Środowisko komend pomocniczych (hooki, sondy exec, pre_stop, diagnostyka):
+// takie jak procesu programu, uzupełnione o zmienne extra. Komendy działają
+// z uprawnieniami monitora, więc nie dostają HOME/USER/LOGNAME użytkownika
+// programu - powłoka roota pisałaby do jego katalogu domowego.
+func (m *Monitor) commandEnv(extra ...string) ([]string, error) {
+	env, err := m.processEnv(nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(env, extra...), nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Wczytuje plik env o podanej treści do środowiska z wcześniej ustawionymi zmiennymi
+func loadEnvText(t *testing.T, text string, preset ...string) (*environ, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.env")
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e := &environ{vars: []string{}}
+	for _, kv := range preset {
+		k, v, _ := strings.Cut(kv, "=")
+		e.set(k, v)
+	}
+	return e, e.loadFile(path)
+}
+
+func TestEnvFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		preset []string
+		key    string
+		want   string
+	}{
+		{name: "zwykła wartość", line: "FOO=bar", key: "FOO", want: "bar"},
+		{name: "spacje wokół", line: "  FOO = bar  ", key: "FOO", want: "bar"},
+		{name: "pusta wartość", line: "FOO=", key: "FOO", want: ""},
+		{name: "prefiks export", line: "export FOO=bar", key: "FOO", want: "bar"},
+		{name: "znak = w wartości", line: "URL=http://h/?a=b", key: "URL", want: "http://h/?a=b"},
+		{name: "komentarz po wartości", line: "FOO=bar # uwaga", key: "FOO", want: "bar"},
+		{name: "# bez spacji to część wartości", line: "FOO=bar#1", key: "FOO", want: "bar#1"},
+		{name: "pojedyncze cudzysłowy dosłownie", line: `FOO='a $HOME \n b'`, preset: []string{"HOME=/root"}, key: "FOO", want: `a $HOME \n b`},
+		{name: "podwójne cudzysłowy z sekwencjami", line: `FOO="a\nb \"c\" d\\e"`, key: "FOO", want: "a\nb \"c\" d\\e"},
+		{name: "podwójne cudzysłowy rozwijane", line: `FOO="${BASE}/x"`, preset: []string{"BASE=/opt"}, key: "FOO", want: "/opt/x"},
+		{name: "# w cudzysłowach", line: `FOO="a # b"`, key: "FOO", want: "a # b"},
+		{name: "komentarz po podwójnych cudzysłowach", line: `FOO="a b" # uwaga`, key: "FOO", want: "a b"},
+		{name: "komentarz po pojedynczych cudzysłowach", line: `FOO='a b' # uwaga`, key: "FOO", want: "a b"},
+		{name: "komentarz bez spacji po cudzysłowie", line: `FOO="a"#uwaga`, key: "FOO", want: "a"},
+		{name: "tekst po cudzysłowie", line: `FOO="a"b`, key: "FOO", want: `"a"b`},
+		{name: "niezamknięty cudzysłów", line: `FOO="abc`, key: "FOO", want: `"abc`},
+		{name: "rozwijanie $VAR", line: "FOO=$BASE/bin", preset: []string{"BASE=/opt"}, key: "FOO", want: "/opt/bin"},
+		{name: "rozwijanie ${VAR}", line: "FOO=${BASE}bin", preset: []string{"BASE=/opt/"}, key: "FOO", want: "/opt/bin"},
+		{name: "domyślna wartość nieustawionej", line: "FOO=${MISSING:-def}", key: "FOO", want: "def"},
+		{name: "domyślna wartość pustej", line: "FOO=${EMPTY:-def}", preset: []string{"EMPTY="}, key: "FOO", want: "def"},
+		{name: "domyślna wartość pominięta", line: "FOO=${BASE:-def}", preset: []string{"BASE=set"}, key: "FOO", want: "set"},
+		{name: "pusta bez domyślnej", line: "FOO=${EMPTY}x", preset: []string{"EMPTY="}, key: "FOO", want: "x"},
+		{name: "nieustawiona zmienna", line: "FOO=$MISSING", key: "FOO", want: ""},
+		{name: "$$ to znak $", line: "FOO=cena $$5", key: "FOO", want: "cena $5"},
+		{name: "$$ w podwójnych cudzysłowach", line: `FOO="$$HOME"`, preset: []string{"HOME=/root"}, key: "FOO", want: "$HOME"},
+		{name: "nadpisanie wcześniejszej", line: "HOME=/home/app", preset: []string{"HOME=/root"}, key: "HOME", want: "/home/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := loadEnvText(t, tt.line+"\n", tt.preset...)
+			if err != nil {
+				t.Fatalf("loadFile: %v", err)
+			}
+			got, ok := e.get(tt.key)
+			if !ok {
+				t.Fatalf("brak zmiennej %s: %v", tt.key, e.vars)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %q, oczekiwano %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvFileOrder(t *testing.T) {
+	e, err := loadEnvText(t, `# komentarz
+
+export BASE=/opt/app
+DATA=${BASE}/data
+BASE=/srv
+LOG=$BASE/log
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Zmienne odwołują się do wartości z chwili ustawienia; kolejność
+	// w środowisku to kolejność pierwszego ustawienia
+	want := []string{"BASE=/srv", "DATA=/opt/app/data", "LOG=/srv/log"}
+	if strings.Join(e.vars, " ") != strings.Join(want, " ") {
+		t.Errorf("środowisko = %v, oczekiwano %v", e.vars, want)
+	}
+}
+
+func TestEnvFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		line string // Oczekiwany fragment "plik:linia"
+	}{
+		{"brak znaku =", "FOO=1\nBAR\n", ":2:"},
+		{"pusty klucz", "=1\n", ":1:"},
+		{"spacja w kluczu", "A=1\n\n# x\nFOO BAR=1\n", ":4:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadEnvText(t, tt.text)
+			if err == nil {
+				t.Fatal("oczekiwano błędu")
+			}
+			if !strings.Contains(err.Error(), tt.line) {
+				t.Errorf("błąd %q nie wskazuje linii %s", err, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Rodzaje zdarzeń programu
+const (
+	eventStarted          = "started"           // Proces uruchomiony
+	eventExited           = "exited"            // Proces zakończony
+	eventRestartRequested = "restart-requested" // Zlecono restart programu
+	eventStopEscalated    = "stop-escalated"    // Proces nie zakończył się - kolejny krok zatrzymania
+	eventLogTimeout       = "log-timeout"       // Przekroczono limit ciszy w logach
+	eventProbeFailed      = "probe-failed"      // Sonda osiągnęła próg niepowodzeń
+	eventResourceLimit    = "resource-limit"    // Strażnik wykrył przekroczenie limitu zasobów
+	eventLeakDetected     = "leak-detected"     // Trend RSS prowadzi do limitu pamięci
+	eventConfigReloaded   = "config-reloaded"   // Przeładowano konfigurację (bez programu)
+)
+
+// Wszystkie rodzaje zdarzeń (do walidacji konfiguracji)
+var eventTypes = []string{
+	eventStarted, eventExited, eventRestartRequested, eventStopEscalated,
+	eventLogTimeout, eventProbeFailed, eventResourceLimit, eventLeakDetected, eventConfigReloaded,
+}
+
+// Czy nazwa jest znanym rodzajem zdarzenia
+func isEventType(name string) bool {
+	return containsString(eventTypes, name)
+}
+
+// Pojemność kolejki zdarzeń jednego subskrybenta
+const eventQueueSize = 256
+
+// Zdarzenie z życia nadzorowanego programu
+type Event struct {
+	Time     time.Time `json:"time"`                       // Kiedy wystąpiło zdarzenie
+	Program  string    `json:"program,omitempty"`          // Nazwa programu
+	Type     string    `json:"type"`                       // Rodzaj zdarzenia (stałe event*)
+	PID      int       `json:"pid,omitempty"`              // PID procesu
+	Reason   string    `json:"reason,omitempty"`           // Powód lub opis zdarzenia
+	ExitCode *int      `json:"exit_code,omitempty"`        // Kod wyjścia (zdarzenie exited)
+	Signal   string    `json:"signal,omitempty"`           // Sygnał, który zakończył proces lub wysłany sygnał
+	Cause    string    `json:"cause,omitempty"`            // Przyczyna restartu (stałe cause*)
+	Probe    string    `json:"probe,omitempty"`            // Nazwa sondy
+	Expected bool      `json:"expected,omitempty"`         // Zakończenie zlecone przez monitor
+	Uptime   float64   `json:"uptime_seconds,omitempty"`   // Czas działania procesu
+	Duration float64   `json:"duration_seconds,omitempty"` // Czas trwania zatrzymania lub ciszy w logach
+}
+
+// Konfiguracja dziennika zdarzeń i wyjścia tekstowego
+type EventsConfig struct {
+	File  string `json:"file"`  // Plik dziennika JSON lines lub "stderr" (pusty = wyłączony)
+	Human *bool  `json:"human"` // Komunikaty tekstowe na stdout (domyślnie true)
+}
+
+// Czy wypisywać komunikaty tekstowe
+func (c *EventsConfig) humanOutput() bool {
+	return c.Human == nil || *c.Human
+}
+
+// Dokąd trafiają komunikaty tekstowe monitora (io.Discard gdy wyłączone)
+var humanOutput io.Writer = os.Stdout
+
+// Rozsyła zdarzenia do subskrybentów (np. połączeń "events" gniazda
+// sterującego). Wolny subskrybent traci zdarzenia zamiast blokować nadzór.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]bool
+	log  *json.Encoder // Dziennik zdarzeń zapisywany synchronicznie (nil gdy brak)
+	file *os.File      // Plik dziennika (nil dla stderr)
+}
+
+// Tworzy pustą szynę zdarzeń
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[chan Event]bool)}
+}
+
+// Otwiera dziennik zdarzeń w formacie JSON lines
+func (b *eventBus) openLog(path string) error {
+	var w io.Writer = os.Stderr
+	if path != "stderr" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("nie można otworzyć dziennika zdarzeń: %v", err)
+		}
+		b.file = f
+		w = f
+	}
+
+	b.mu.Lock()
+	b.log = json.NewEncoder(w)
+	b.mu.Unlock()
+	return nil
+}
+
+// Zamyka dziennik zdarzeń
+func (b *eventBus) closeLog() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.log = nil
+	if b.file != nil {
+		b.file.Close()
+		b.file = nil
+	}
+}
+
+// Zapisuje zdarzenie w dzienniku i wysyła je do wszystkich subskrybentów
+// bez blokowania
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.log != nil {
+		b.log.Encode(e)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Rejestruje nowego subskrybenta
+func (b *eventBus) subscribe() chan Event {
+	ch := make(chan Event, eventQueueSize)
+	b.mu.Lock()
+	b.subs[ch] = true
+	b.mu.Unlock()
+	return ch
+}
+
+// Wyrejestrowuje subskrybenta
+func (b *eventBus) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+// Publikuje zdarzenie programu (bez szyny zdarzeń nic nie robi)
+func (m *Monitor) emit(e Event) {
+	if m.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	e.Program = m.name
+	m.events.publish(e)
+}
+
+// Publikuje zdarzenie przekroczenia limitu ciszy w logach
+func (m *Monitor) emitLogTimeout(silence time.Duration) {
+	m.mutex.RLock()
+	pid := m.pidUnsafe()
+	m.mutex.RUnlock()
+
+	m.emit(Event{
+		Type:     eventLogTimeout,
+		PID:      pid,
+		Reason:   fmt.Sprintf("brak zmian w logach przez %v (limit: %v)", silence.Round(time.Second), m.timeout),
+		Duration: silence.Seconds(),
+	})
+}
+
+// Zdarzenie zakończenia procesu
+func exitEvent(exit *processExit) Event {
+	e := Event{
+		Type:     eventExited,
+		PID:      exit.pid,
+		Reason:   exit.describe(),
+		Expected: exit.expected,
+		Uptime:   exit.exitedAt.Sub(exit.startedAt).Seconds(),
+	}
+	if exit.oomKilled {
+		e.Cause = causeOOM
+	}
+	if exit.signal != 0 {
+		e.Signal = signalName(exit.signal)
+	} else if exit.state != nil {
+		code := exit.exitCode
+		e.ExitCode = &code
+	}
+	return e
+}
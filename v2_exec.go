@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Domyślny interpreter komendy w trybie powłoki
+const defaultShell = "sh"
+
+// PATH używany, gdy środowisko programu go nie zawiera (np. clear_env)
+const defaultExecPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// Interpreter z argumentami, np. "bash -o pipefail" (domyślnie sh)
+func shellArgv(shell string) []string {
+	if fields := strings.Fields(shell); len(fields) > 0 {
+		return fields
+	}
+	return []string{defaultShell}
+}
+
+// Opis komendy programu do komunikatów: komenda powłoki albo argumenty
+// (z cudzysłowami tam, gdzie są potrzebne)
+func commandLine(command string, args []string) string {
+	if len(args) == 0 {
+		return command
+	}
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\n\"'\\$`;&|<>()*?[]#~") {
+			a = strconv.Quote(a)
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Buduje komendę procesu programu. W trybie args plik wykonywalny jest
+// uruchamiany bezpośrednio, w trybie powłoki - przez interpreter z -c.
+// Plik jest wyszukiwany w PATH ze środowiska programu, żeby brak pliku
+// lub uprawnień zgłosić przy starcie, a nie jako natychmiastowe zakończenie.
+func (m *Monitor) buildCommand(env []string) (*exec.Cmd, error) {
+	argv := m.args
+	if len(argv) == 0 {
+		argv = append(append([]string{}, m.shell...), "-c", m.command)
+	}
+	path, err := lookExecutable(argv[0], m.workingDir, env)
+	if err != nil {
+		return nil, err
+	}
+	return &exec.Cmd{Path: path, Args: argv}, nil
+}
+
+// Wyszukuje plik wykonywalny: ścieżka z "/" jest względna wobec katalogu
+// roboczego, a sama nazwa - szukana w PATH środowiska programu
+func lookExecutable(file, dir string, env []string) (string, error) {
+	if strings.Contains(file, "/") {
+		path := file
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		if err := checkExecutable(path); err != nil {
+			return "", err
+		}
+		// Względna ścieżka jest rozwiązywana przez exec względem Dir
+		return file, nil
+	}
+
+	pathVar := defaultExecPath
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "PATH="); ok {
+			pathVar = v
+		}
+	}
+	var denied error
+	for _, d := range filepath.SplitList(pathVar) {
+		if d == "" {
+			continue
+		}
+		path := filepath.Join(d, file)
+		err := checkExecutable(path)
+		if err == nil {
+			return path, nil
+		}
+		if denied == nil && !errors.Is(err, fs.ErrNotExist) {
+			denied = err
+		}
+	}
+	if denied != nil {
+		return "", denied
+	}
+	return "", fmt.Errorf("nie znaleziono pliku wykonywalnego %q w PATH (%s)", file, pathVar)
+}
+
+// Sprawdza, czy plik istnieje i ma prawo wykonania
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("nie znaleziono pliku wykonywalnego %q: %w", path, fs.ErrNotExist)
+		}
+		return fmt.Errorf("brak dostępu do %q: %v", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%q jest katalogiem, a nie plikiem wykonywalnym", path)
+	}
+	if info.Mode()&0o111 == 0 {
+		return fmt.Errorf("brak uprawnień do uruchomienia %q (permission denied)", path)
+	}
+	return nil
+}
+
+// Opis błędu uruchomienia. Błąd exec z procesu potomnego (np. po zmianie
+// użytkownika albo brak interpretera ze shebang) jest zgłaszany przez Start.
+func startError(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) && pe.Op == "fork/exec" {
+		switch {
+		case errors.Is(pe.Err, syscall.ENOENT):
+			return fmt.Errorf("nie znaleziono pliku wykonywalnego %q lub jego interpretera", pe.Path)
+		case errors.Is(pe.Err, syscall.EACCES), errors.Is(pe.Err, syscall.EPERM):
+			return fmt.Errorf("brak uprawnień do uruchomienia %q (permission denied)", pe.Path)
+		case errors.Is(pe.Err, syscall.ENOEXEC):
+			return fmt.Errorf("%q nie jest rozpoznawanym plikiem wykonywalnym (brak shebang?)", pe.Path)
+		}
+	}
+	return fmt.Errorf("nie można uruchomić procesu: %v", err)
+}
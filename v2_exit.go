@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Wynik oczekiwania na zakończenie procesu. Pola opisujące zakończenie są
+// zapisywane przez goroutine czekającą na proces przed zamknięciem kanału done.
+type processExit struct {
+	done      chan struct{}    // Zamykany po zakończeniu procesu
+	pid       int              // PID procesu
+	startedAt time.Time        // Kiedy proces wystartował
+	exitedAt  time.Time        // Kiedy proces został zebrany
+	err       error            // Błąd zwrócony przez Wait (nil = kod wyjścia 0)
+	state     *os.ProcessState // Stan zakończonego procesu
+	exitCode  int              // Kod wyjścia (-1 gdy zabity sygnałem)
+	signal    syscall.Signal   // Sygnał, który zakończył proces (0 gdy brak)
+	core      bool             // Czy powstał zrzut pamięci (core dump)
+	userTime  time.Duration    // Czas CPU w trybie użytkownika
+	sysTime   time.Duration    // Czas CPU w trybie jądra
+	maxRSS    int64            // Maksymalna pamięć rezydentna w KB
+	expected  bool             // Zakończenie zlecone przez monitor (chronione mutexem monitora)
+	oomKilled bool             // OOM killer zabijał procesy w cgroup programu
+	orphans   []procEntry      // Pozostałe procesy drzewa w chwili zakończenia
+}
+
+// Uruchamia goroutine czekającą na zakończenie procesu - dzięki temu
+// proces jest od razu zbierany i nie zostaje zombie. Po zakończeniu
+// wynik jest wysyłany do pętli nadzoru przez kanał events.
+func waitForExit(cmd *exec.Cmd, events chan<- *processExit) *processExit {
+	exit := &processExit{
+		done:      make(chan struct{}),
+		pid:       cmd.Process.Pid,
+		startedAt: time.Now(),
+	}
+	go func() {
+		// Migawka przed zebraniem procesu - później jego numer grupy
+		// może należeć do zupełnie innego procesu
+		exit.orphans = orphanSnapshot(exit.pid)
+		exit.err = cmd.Wait()
+		exit.exitedAt = time.Now()
+		exit.record(cmd.ProcessState)
+		close(exit.done)
+
+		// Pętla nadzoru ma też zapasowe sprawdzanie co interwał,
+		// więc przy pełnym kanale zdarzenie można pominąć
+		select {
+		case events <- exit:
+		default:
+		}
+	}()
+	return exit
+}
+
+// Zapisuje status wyjścia i zużycie zasobów z ProcessState
+func (pe *processExit) record(state *os.ProcessState) {
+	pe.state = state
+	pe.exitCode = -1
+	if state == nil {
+		return
+	}
+	pe.exitCode = state.ExitCode()
+
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		pe.signal = ws.Signal()
+		pe.core = ws.CoreDump()
+	}
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		pe.userTime = time.Duration(ru.Utime.Nano())
+		pe.sysTime = time.Duration(ru.Stime.Nano())
+		pe.maxRSS = int64(ru.Maxrss)
+	}
+}
+
+// Czy proces już się zakończył
+func (pe *processExit) exited() bool {
+	select {
+	case <-pe.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Czy proces zakończył się błędem (niezerowy kod lub sygnał)
+func (pe *processExit) failed() bool {
+	return pe.state == nil || !pe.state.Success()
+}
+
+// Opis zakończenia procesu, np. "kod wyjścia 1" lub "sygnał 11 (segmentation fault), zrzut pamięci"
+func (pe *processExit) describe() string {
+	if pe.state == nil {
+		return fmt.Sprintf("błąd oczekiwania: %v", pe.err)
+	}
+	if pe.signal != 0 {
+		desc := fmt.Sprintf("sygnał %d (%v)", int(pe.signal), pe.signal)
+		if pe.core {
+			desc += ", zrzut pamięci"
+		}
+		if pe.oomKilled {
+			desc += ", OOM killer"
+		}
+		return desc
+	}
+	return fmt.Sprintf("kod wyjścia %d", pe.exitCode)
+}
+
+// Pełny opis zakończenia wraz z czasem działania i zużyciem zasobów
+func (pe *processExit) summary() string {
+	return fmt.Sprintf("%s, działał %v, CPU: użytkownik %v / system %v, max RSS: %d KB",
+		pe.describe(), pe.exitedAt.Sub(pe.startedAt).Round(time.Millisecond),
+		pe.userTime.Round(time.Millisecond), pe.sysTime.Round(time.Millisecond), pe.maxRSS)
+}
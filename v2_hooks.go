@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Domyślny limit czasu hooka
+const defaultHookTimeout = 30 * time.Second
+
+// Po jakim czasie od przerwania hooka przestać czekać na jego wyjście
+const hookWaitDelay = time.Second
+
+// Nazwy hooków cyklu życia (w komunikatach i zmiennej MONITOR_HOOK)
+const (
+	hookPreStart  = "pre_start"
+	hookPostStart = "post_start"
+	hookPostStop  = "post_stop"
+	hookOnCrash   = "on_crash"
+)
+
+// Komenda uruchamiana w danym momencie cyklu życia programu
+type HookConfig struct {
+	Command       string   `json:"command"`        // Komenda uruchamiana przez sh -c
+	Timeout       Duration `json:"timeout"`        // Limit czasu (domyślnie 30s)
+	IgnoreFailure bool     `json:"ignore_failure"` // pre_start: niepowodzenie nie blokuje startu
+}
+
+// Hooki cyklu życia programu
+type HooksConfig struct {
+	PreStart  *HookConfig `json:"pre_start"`  // Przed uruchomieniem - niepowodzenie blokuje start
+	PostStart *HookConfig `json:"post_start"` // Po uruchomieniu procesu (w tle)
+	PostStop  *HookConfig `json:"post_stop"`  // Po zakończeniu procesu i jego potomków
+	OnCrash   *HookConfig `json:"on_crash"`   // Po awarii lub przed zabiciem zawieszonego procesu
+}
+
+// Wszystkie skonfigurowane hooki z nazwami
+func (h *HooksConfig) all() map[string]*HookConfig {
+	return map[string]*HookConfig{
+		hookPreStart:  h.PreStart,
+		hookPostStart: h.PostStart,
+		hookPostStop:  h.PostStop,
+		hookOnCrash:   h.OnCrash,
+	}
+}
+
+// Uzupełnia brakujące ustawienia hooków
+func (h *HooksConfig) applyDefaults() {
+	for _, hook := range h.all() {
+		if hook != nil && hook.Timeout.Duration == 0 {
+			hook.Timeout.Duration = defaultHookTimeout
+		}
+	}
+}
+
+// Sprawdza poprawność konfiguracji hooków
+func (h *HooksConfig) validate() error {
+	for name, hook := range h.all() {
+		if hook == nil {
+			continue
+		}
+		if hook.Command == "" {
+			return fmt.Errorf("hooks.%s: brak command", name)
+		}
+		if hook.Timeout.Duration < 0 {
+			return fmt.Errorf("hooks.%s: timeout nie może być ujemny", name)
+		}
+	}
+	return nil
+}
+
+// Opis sytuacji, w której uruchamiany jest hook
+type hookContext struct {
+	pid     int          // PID procesu (0 gdy brak)
+	cause   string       // Przyczyna restartu (stałe cause*)
+	reason  string       // Powód restartu lub opis zdarzenia
+	exit    *processExit // Wynik zakończenia procesu (nil gdy proces działa)
+	diagDir string       // Katalog zebranej diagnostyki (pusty gdy brak)
+}
+
+// Zmienne MONITOR_* opisujące sytuację hooka
+func (m *Monitor) hookEnv(name string, hc hookContext) []string {
+	env := []string{
+		"MONITOR_HOOK=" + name,
+		"MONITOR_PROGRAM=" + m.name,
+		"MONITOR_LOG_FILE=" + m.logFile,
+	}
+	if hc.pid != 0 {
+		env = append(env, "MONITOR_PID="+strconv.Itoa(hc.pid))
+	}
+	if hc.cause != "" {
+		env = append(env, "MONITOR_CAUSE="+hc.cause)
+	}
+	if hc.reason != "" {
+		env = append(env, "MONITOR_REASON="+hc.reason)
+	}
+	if hc.diagDir != "" {
+		env = append(env, "MONITOR_DIAG_DIR="+hc.diagDir)
+	}
+	if exit := hc.exit; exit != nil {
+		if exit.signal != 0 {
+			env = append(env, "MONITOR_SIGNAL="+signalName(exit.signal))
+		} else {
+			env = append(env, "MONITOR_EXIT_CODE="+strconv.Itoa(exit.exitCode))
+		}
+		env = append(env,
+			"MONITOR_UPTIME="+strconv.FormatFloat(exit.exitedAt.Sub(exit.startedAt).Seconds(), 'f', 3, 64),
+			"MONITOR_CORE_DUMP="+strconv.FormatBool(exit.core))
+	}
+	return env
+}
+
+// Komenda we własnej grupie procesów - anulowanie kontekstu zabija całą
+// grupę, a nie tylko powłokę
+func groupCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = hookWaitDelay
+	return cmd
+}
+
+// Uruchamia hook i czeka na jego zakończenie. Po przekroczeniu limitu
+// czasu zabijana jest cała grupa procesów hooka.
+func (m *Monitor) runHook(name string, hook *HookConfig, hc hookContext) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout.Duration)
+	defer cancel()
+
+	m.logf("Hook %s: %s\n", name, hook.Command)
+	env, err := m.commandEnv(m.hookEnv(name, hc)...)
+	if err != nil {
+		return err
+	}
+	cmd := groupCommand(ctx, "sh", "-c", hook.Command)
+	cmd.Dir = m.workingDir
+	cmd.Env = env
+
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("przekroczono limit czasu %v", hook.Timeout.Duration)
+	} else if err != nil {
+		err = fmt.Errorf("%v %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// Uruchamia hook, którego niepowodzenie jest tylko odnotowywane
+func (m *Monitor) runHookLogged(name string, hook *HookConfig, hc hookContext) {
+	if err := m.runHook(name, hook, hc); err != nil {
+		m.logf("Hook %s nie powiódł się: %v\n", name, err)
+	}
+}
+
+// Hook pre_start - błąd oznacza, że proces nie może zostać uruchomiony
+func (m *Monitor) runPreStart() error {
+	hook := m.hooks.PreStart
+	if hook == nil {
+		return nil
+	}
+	if hook.IgnoreFailure {
+		m.runHookLogged(hookPreStart, hook, hookContext{})
+		return nil
+	}
+	if err := m.runHook(hookPreStart, hook, hookContext{}); err != nil {
+		return fmt.Errorf("hook %s nie powiódł się: %v", hookPreStart, err)
+	}
+	return nil
+}
+
+// Hook post_start - uruchamiany w tle, żeby nie opóźniać nadzoru
+func (m *Monitor) runPostStartUnsafe(pid int) {
+	if hook := m.hooks.PostStart; hook != nil {
+		go m.runHookLogged(hookPostStart, hook, hookContext{pid: pid})
+	}
+}
+
+// Hook post_stop po zakończeniu procesu i jego potomków
+func (m *Monitor) runPostStop(exit *processExit) {
+	hook := m.hooks.PostStop
+	if hook == nil || exit == nil {
+		return
+	}
+	m.runHookLogged(hookPostStop, hook, hookContext{pid: exit.pid, exit: exit})
+}
+
+// Hook on_crash - po nieoczekiwanym zakończeniu z błędem (exit != nil)
+// albo przed zabiciem zawieszonego procesu, dopóki jeszcze działa
+func (m *Monitor) runCrashHook(cause, reason string, exit *processExit, diagDir string) {
+	hook := m.hooks.OnCrash
+	if hook == nil {
+		return
+	}
+	hc := hookContext{cause: cause, reason: reason, exit: exit, diagDir: diagDir}
+	if exit != nil {
+		if exit.signal == 0 && exit.exitCode == 0 {
+			return
+		}
+		hc.pid = exit.pid
+	} else {
+		m.mutex.RLock()
+		hc.pid = m.pidUnsafe()
+		m.mutex.RUnlock()
+	}
+	m.runHookLogged(hookOnCrash, hook, hc)
+}
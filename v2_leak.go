@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Domyślne ustawienia wykrywania wycieku pamięci
+const (
+	defaultLeakWindow     = time.Hour
+	defaultLeakMinSamples = 10
+	defaultLeakMinR2      = 0.5
+	defaultLeakMargin     = 15 * time.Minute
+)
+
+// Prognozy dalsze niż rok nie są uznawane za wyciek
+const leakMaxHorizon = 365 * 24 * time.Hour
+
+// Wykrywanie wycieku pamięci: trend RSS drzewa procesów dopasowany prostą
+// w przesuwnym oknie i restart zaplanowany przed osiągnięciem limitu
+type LeakConfig struct {
+	CeilingMB      int      `json:"ceiling_mb"`      // Limit RSS w MB, którego osiągnięcie jest przewidywane (0 = wyłączone)
+	Window         Duration `json:"window"`          // Okno próbek do dopasowania trendu (domyślnie 1h)
+	MinSamples     int      `json:"min_samples"`     // Minimalna liczba próbek w oknie (domyślnie 10)
+	MinR2          float64  `json:"min_r2"`          // Minimalne dopasowanie prostej R² (domyślnie 0.5)
+	Margin         Duration `json:"margin"`          // Zapas przed przewidywanym osiągnięciem limitu (domyślnie 15m)
+	RestartWindows []string `json:"restart_windows"` // Okna niskiego ruchu "GG:MM-GG:MM" (czas lokalny)
+	Action         string   `json:"action"`          // "restart" (domyślnie) lub "alert" - tylko prognoza
+}
+
+// Uzupełnia brakujące ustawienia wykrywania wycieku
+func (l *LeakConfig) applyDefaults() {
+	if l.Window.Duration == 0 {
+		l.Window.Duration = defaultLeakWindow
+	}
+	if l.MinSamples == 0 {
+		l.MinSamples = defaultLeakMinSamples
+	}
+	if l.MinR2 == 0 {
+		l.MinR2 = defaultLeakMinR2
+	}
+	if l.Margin.Duration == 0 {
+		l.Margin.Duration = defaultLeakMargin
+	}
+	if l.Action == "" {
+		l.Action = watchdogRestart
+	}
+}
+
+// Sprawdza poprawność ustawień wykrywania wycieku
+func (l *LeakConfig) validate() error {
+	if l.CeilingMB < 0 {
+		return fmt.Errorf("leak: ceiling_mb nie może być ujemny")
+	}
+	if l.Window.Duration <= 0 || l.Margin.Duration < 0 || l.MinSamples < 3 {
+		return fmt.Errorf("leak: window musi być dodatni, margin nieujemny, a min_samples >= 3")
+	}
+	if l.MinR2 < 0 || l.MinR2 > 1 {
+		return fmt.Errorf("leak: min_r2 musi być w zakresie 0-1")
+	}
+	if l.Action != watchdogRestart && l.Action != watchdogAlert {
+		return fmt.Errorf("leak: nieznana akcja %q (dozwolone: %s, %s)", l.Action, watchdogRestart, watchdogAlert)
+	}
+	if _, err := parseRestartWindows(l.RestartWindows); err != nil {
+		return fmt.Errorf("leak: %v", err)
+	}
+	return nil
+}
+
+// Czy wykrywanie wycieku jest włączone
+func (l *LeakConfig) enabled() bool {
+	return l.CeilingMB > 0
+}
+
+// Dobowe okno niskiego ruchu w minutach od północy (koniec może
+// przypadać następnego dnia)
+type restartWindow struct {
+	start, end int
+}
+
+// Parsuje okna "GG:MM-GG:MM"
+func parseRestartWindows(specs []string) ([]restartWindow, error) {
+	windows := make([]restartWindow, 0, len(specs))
+	for _, spec := range specs {
+		from, to, ok := strings.Cut(spec, "-")
+		start, err1 := parseClock(from)
+		end, err2 := parseClock(to)
+		if !ok || err1 != nil || err2 != nil || start == end {
+			return nil, fmt.Errorf("nieprawidłowe okno restartu %q (oczekiwano np. \"02:00-05:00\")", spec)
+		}
+		if end < start {
+			end += 24 * 60
+		}
+		windows = append(windows, restartWindow{start, end})
+	}
+	return windows, nil
+}
+
+// Parsuje godzinę "GG:MM" na minuty od północy
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hour, err1 := strconv.Atoi(h)
+	min, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || hour > 23 || min < 0 || min > 59 {
+		return 0, fmt.Errorf("nieprawidłowa godzina %q", s)
+	}
+	return hour*60 + min, nil
+}
+
+// Wybiera czas restartu: najpóźniejszy moment w oknie niskiego ruchu
+// przed terminem, a gdy żadne okno nie wypada wcześniej - sam termin
+func planRestart(now, deadline time.Time, windows []restartWindow) time.Time {
+	if !deadline.After(now) {
+		return now
+	}
+	var best time.Time
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for base := midnight.AddDate(0, 0, -1); base.Before(deadline); base = base.AddDate(0, 0, 1) {
+		for _, w := range windows {
+			start := base.Add(time.Duration(w.start) * time.Minute)
+			end := base.Add(time.Duration(w.end) * time.Minute)
+			if !end.After(now) {
+				continue
+			}
+			if start.Before(now) {
+				start = now
+			}
+			if start.Before(deadline) && start.After(best) {
+				best = start
+			}
+		}
+	}
+	if best.IsZero() {
+		return deadline
+	}
+	return best
+}
+
+// Próbka RSS do dopasowania trendu
+type leakSample struct {
+	at  time.Time
+	rss float64
+}
+
+// Próbki RSS bieżącego procesu. Używane tylko w pętli nadzoru.
+type leakTracker struct {
+	samples  []leakSample
+	reported bool // Czy zgłoszono już wykryty trend
+}
+
+// Prognoza wycieku pamięci widoczna w statusie programu
+type leakPrediction struct {
+	rate      float64   // Przyrost RSS w bajtach na sekundę
+	ceilingAt time.Time // Przewidywane osiągnięcie limitu
+	plannedAt time.Time // Zaplanowany restart (zero w trybie alert)
+}
+
+// Dopasowuje prostą rss = a + b*t metodą najmniejszych kwadratów.
+// Zwraca nachylenie (bajty/s), wartość w chwili at i współczynnik R².
+func fitTrend(samples []leakSample, at time.Time) (slope, value, r2 float64) {
+	n := float64(len(samples))
+	t0 := samples[0].at
+	var sx, sy, sxx, sxy float64
+	for _, s := range samples {
+		x := s.at.Sub(t0).Seconds()
+		sx += x
+		sy += s.rss
+		sxx += x * x
+		sxy += x * s.rss
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0, sy / n, 0
+	}
+	slope = (n*sxy - sx*sy) / den
+	intercept := (sy - slope*sx) / n
+
+	mean := sy / n
+	var ssRes, ssTot float64
+	for _, s := range samples {
+		x := s.at.Sub(t0).Seconds()
+		d := s.rss - (intercept + slope*x)
+		ssRes += d * d
+		ssTot += (s.rss - mean) * (s.rss - mean)
+	}
+	if ssTot > 0 {
+		r2 = 1 - ssRes/ssTot
+	}
+	return slope, intercept + slope*at.Sub(t0).Seconds(), r2
+}
+
+// Prognoza wycieku z próbek okna; zerowa, gdy trendu nie ma lub próbek
+// jest za mało. Prognoza dopiero gdy próbki obejmują co najmniej połowę
+// okna - rozgrzewka po starcie nie jest wyciekiem.
+func predictLeak(cfg *LeakConfig, samples []leakSample, now time.Time) leakPrediction {
+	var pred leakPrediction
+	if len(samples) < cfg.MinSamples || now.Sub(samples[0].at) < cfg.Window.Duration/2 {
+		return pred
+	}
+	slope, current, r2 := fitTrend(samples, now)
+	ceiling := float64(int64(cfg.CeilingMB) << 20)
+	remaining := math.Max(0, (ceiling-current)/slope) * float64(time.Second)
+	if slope > 0 && r2 >= cfg.MinR2 && remaining < float64(leakMaxHorizon) {
+		pred.rate = slope
+		pred.ceilingAt = now.Add(time.Duration(remaining))
+		if cfg.Action == watchdogRestart {
+			windows, _ := parseRestartWindows(cfg.RestartWindows)
+			pred.plannedAt = planRestart(now, pred.ceilingAt.Add(-cfg.Margin.Duration), windows)
+		}
+	}
+	return pred
+}
+
+// Dodaje próbkę RSS, odświeża prognozę i zwraca powód restartu,
+// gdy nadszedł zaplanowany czas
+func (m *Monitor) observeLeak(usage *resourceUsage) string {
+	cfg := &m.leakCfg
+	if usage == nil || !cfg.enabled() {
+		return ""
+	}
+	t := &m.leak
+	t.samples = append(t.samples, leakSample{usage.at, float64(usage.rssBytes)})
+	cutoff := usage.at.Add(-cfg.Window.Duration)
+	for len(t.samples) > 0 && t.samples[0].at.Before(cutoff) {
+		t.samples = t.samples[1:]
+	}
+
+	pred := predictLeak(cfg, t.samples, usage.at)
+	m.mutex.Lock()
+	m.leakPlan = pred
+	m.mutex.Unlock()
+
+	if pred.rate == 0 {
+		if t.reported {
+			m.logf("Trend wzrostu pamięci ustąpił - prognoza wycieku anulowana\n")
+			t.reported = false
+		}
+		return ""
+	}
+
+	summary := fmt.Sprintf("wyciek pamięci %+.1f MB/h, limit %d MB przewidywany %s",
+		pred.rate*3600/(1<<20), cfg.CeilingMB, pred.ceilingAt.Format("2006-01-02 15:04"))
+	if !t.reported {
+		t.reported = true
+		msg := summary
+		if !pred.plannedAt.IsZero() {
+			msg += ", restart zaplanowany " + pred.plannedAt.Format("2006-01-02 15:04")
+		}
+		m.logf("UWAGA! %s\n", msg)
+		m.emit(Event{Type: eventLeakDetected, PID: usage.pid, Reason: msg})
+	}
+	if !pred.plannedAt.IsZero() && !usage.at.Before(pred.plannedAt) {
+		return "planowany restart - " + summary
+	}
+	return ""
+}
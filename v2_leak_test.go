@@ -0,0 +1,296 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Próbki co minutę: rss(i) dla i = 0..n-1
+func leakSeries(t0 time.Time, n int, rss func(i int) float64) []leakSample {
+	samples := make([]leakSample, n)
+	for i := range samples {
+		samples[i] = leakSample{at: t0.Add(time.Duration(i) * time.Minute), rss: rss(i)}
+	}
+	return samples
+}
+
+const mb = 1 << 20
+
+func TestFitTrend(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		samples   []leakSample
+		at        time.Time
+		slope     float64 // Bajty na sekundę
+		value     float64
+		r2        float64
+		tolerance float64 // Dopuszczalny błąd względny (0 = wynik dokładny)
+	}{
+		{
+			name:    "stały poziom",
+			samples: leakSeries(t0, 30, func(int) float64 { return 500 * mb }),
+			at:      t0.Add(29 * time.Minute),
+			slope:   0, value: 500 * mb, r2: 0,
+		},
+		{
+			name:    "wzrost liniowy",
+			samples: leakSeries(t0, 30, func(i int) float64 { return 500*mb + float64(i)*mb }),
+			at:      t0.Add(40 * time.Minute),
+			slope:   mb / 60.0, value: 540 * mb, r2: 1,
+		},
+		{
+			name:    "spadek liniowy",
+			samples: leakSeries(t0, 30, func(i int) float64 { return 500*mb - float64(i)*mb }),
+			at:      t0.Add(29 * time.Minute),
+			slope:   -mb / 60.0, value: 471 * mb, r2: 1,
+		},
+		{
+			name: "wzrost z szumem",
+			samples: leakSeries(t0, 60, func(i int) float64 {
+				return 500*mb + float64(i)*mb + float64(i%2*2-1)*2*mb
+			}),
+			at:    t0.Add(59 * time.Minute),
+			slope: mb / 60.0, value: 559 * mb, r2: 0.99, tolerance: 0.05,
+		},
+		{
+			name: "próbki z jednej chwili",
+			samples: []leakSample{
+				{at: t0, rss: 100 * mb},
+				{at: t0, rss: 300 * mb},
+			},
+			at:    t0,
+			slope: 0, value: 200 * mb, r2: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slope, value, r2 := fitTrend(tt.samples, tt.at)
+			rel := math.Max(tt.tolerance, 1e-9)
+			if !approx(slope, tt.slope, rel) {
+				t.Errorf("nachylenie = %v, oczekiwano %v", slope, tt.slope)
+			}
+			if !approx(value, tt.value, rel) {
+				t.Errorf("wartość = %v MB, oczekiwano %v MB", value/mb, tt.value/mb)
+			}
+			if !approx(r2, tt.r2, rel) {
+				t.Errorf("R² = %v, oczekiwano %v", r2, tt.r2)
+			}
+		})
+	}
+}
+
+// Czy wartości są równe z dokładnością względną rel (bezwzględną dla |want| < 1)
+func approx(got, want, rel float64) bool {
+	return math.Abs(got-want) <= rel*math.Max(math.Abs(want), 1)
+}
+
+func TestPredictLeak(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := LeakConfig{CeilingMB: 1000}
+	cfg.applyDefaults()
+	alert := cfg
+	alert.Action = watchdogAlert
+
+	growth := func(i int) float64 { return 500*mb + float64(i)*mb }
+
+	// n próbek co 5 minut na tej samej prostej co growth
+	sparse := func(n int) []leakSample {
+		s := make([]leakSample, n)
+		for i := range s {
+			s[i] = leakSample{at: t0.Add(time.Duration(i*5) * time.Minute), rss: growth(i * 5)}
+		}
+		return s
+	}
+
+	tests := []struct {
+		name      string
+		cfg       *LeakConfig
+		samples   []leakSample
+		now       time.Time
+		wantLeak  bool
+		ceilingAt time.Time // Oczekiwane osiągnięcie limitu (gdy wantLeak)
+		planned   bool      // Czy zaplanowano restart
+	}{
+		{
+			name:    "stały poziom",
+			cfg:     &cfg,
+			samples: leakSeries(t0, 60, func(int) float64 { return 800 * mb }),
+			now:     t0.Add(59 * time.Minute),
+		},
+		{
+			name:      "wzrost liniowy",
+			cfg:       &cfg,
+			samples:   leakSeries(t0, 60, growth),
+			now:       t0.Add(59 * time.Minute),
+			wantLeak:  true,
+			ceilingAt: t0.Add(500 * time.Minute),
+			planned:   true,
+		},
+		{
+			name:      "wzrost liniowy - tryb alert",
+			cfg:       &alert,
+			samples:   leakSeries(t0, 60, growth),
+			now:       t0.Add(59 * time.Minute),
+			wantLeak:  true,
+			ceilingAt: t0.Add(500 * time.Minute),
+		},
+		{
+			name:    "spadek",
+			cfg:     &cfg,
+			samples: leakSeries(t0, 60, func(i int) float64 { return 900*mb - float64(i)*mb }),
+			now:     t0.Add(59 * time.Minute),
+		},
+		{
+			name: "sam szum (niskie R²)",
+			cfg:  &cfg,
+			samples: leakSeries(t0, 60, func(i int) float64 {
+				return 500*mb + float64((i*7919)%13)*10*mb
+			}),
+			now: t0.Add(59 * time.Minute),
+		},
+		{
+			name: "wzrost z szumem",
+			cfg:  &cfg,
+			samples: leakSeries(t0, 60, func(i int) float64 {
+				return growth(i) + float64(i%2*2-1)*2*mb
+			}),
+			now:       t0.Add(59 * time.Minute),
+			wantLeak:  true,
+			ceilingAt: t0.Add(500 * time.Minute),
+			planned:   true,
+		},
+		{
+			name:    "o jedną próbkę za mało",
+			cfg:     &cfg,
+			samples: sparse(defaultLeakMinSamples - 1),
+			now:     t0.Add(40 * time.Minute),
+		},
+		{
+			name:      "minimalna liczba próbek",
+			cfg:       &cfg,
+			samples:   sparse(defaultLeakMinSamples),
+			now:       t0.Add(45 * time.Minute),
+			wantLeak:  true,
+			ceilingAt: t0.Add(500 * time.Minute),
+			planned:   true,
+		},
+		{
+			name:    "próbki sekundę krócej niż pół okna",
+			cfg:     &cfg,
+			samples: leakSeries(t0, 30, growth),
+			now:     t0.Add(30*time.Minute - time.Second),
+		},
+		{
+			name:      "próbki równe pół okna",
+			cfg:       &cfg,
+			samples:   leakSeries(t0, 31, growth),
+			now:       t0.Add(30 * time.Minute),
+			wantLeak:  true,
+			ceilingAt: t0.Add(500 * time.Minute),
+			planned:   true,
+		},
+		{
+			name:    "limit dalej niż rok",
+			cfg:     &cfg,
+			samples: leakSeries(t0, 60, func(i int) float64 { return 500*mb + float64(i)*100 }),
+			now:     t0.Add(59 * time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pred := predictLeak(tt.cfg, tt.samples, tt.now)
+			if (pred.rate > 0) != tt.wantLeak {
+				t.Fatalf("wyciek = %v (%+v), oczekiwano %v", pred.rate > 0, pred, tt.wantLeak)
+			}
+			if !tt.wantLeak {
+				return
+			}
+			if d := pred.ceilingAt.Sub(tt.ceilingAt); d < -5*time.Minute || d > 5*time.Minute {
+				t.Errorf("limit osiągnięty %v, oczekiwano około %v", pred.ceilingAt, tt.ceilingAt)
+			}
+			if pred.plannedAt.IsZero() == tt.planned {
+				t.Errorf("restart zaplanowany = %v, oczekiwano %v", !pred.plannedAt.IsZero(), tt.planned)
+			}
+			if tt.planned {
+				// Bez okien restart wypada na margin przed limitem
+				if want := pred.ceilingAt.Add(-tt.cfg.Margin.Duration); !pred.plannedAt.Equal(want) {
+					t.Errorf("restart %v, oczekiwano %v", pred.plannedAt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanRestart(t *testing.T) {
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	at := func(d int, hm string) time.Time {
+		m, err := parseClock(hm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return day.AddDate(0, 0, d).Add(time.Duration(m) * time.Minute)
+	}
+
+	tests := []struct {
+		name     string
+		windows  []string
+		now      time.Time
+		deadline time.Time
+		want     time.Time
+	}{
+		{"bez okien", nil, at(0, "10:00"), at(0, "18:00"), at(0, "18:00")},
+		{"termin minął", []string{"02:00-05:00"}, at(0, "10:00"), at(0, "09:00"), at(0, "10:00")},
+		{"termin równy teraz", []string{"02:00-05:00"}, at(0, "10:00"), at(0, "10:00"), at(0, "10:00")},
+		{"okno przed terminem", []string{"02:00-05:00"}, at(0, "10:00"), at(1, "12:00"), at(1, "02:00")},
+		{"najpóźniejsze z okien", []string{"02:00-05:00"}, at(0, "01:00"), at(2, "03:00"), at(2, "02:00")},
+		{"termin przed oknem", []string{"02:00-05:00"}, at(0, "10:00"), at(1, "01:59"), at(1, "01:59")},
+		{"termin na początku okna", []string{"02:00-05:00"}, at(0, "10:00"), at(1, "02:00"), at(1, "02:00")},
+		{"minutę po początku okna", []string{"02:00-05:00"}, at(0, "10:00"), at(1, "02:01"), at(1, "02:00")},
+		{"teraz w oknie", []string{"02:00-05:00"}, at(0, "03:00"), at(0, "04:00"), at(0, "03:00")},
+		{"teraz na końcu okna", []string{"02:00-05:00"}, at(0, "05:00"), at(0, "09:00"), at(0, "09:00")},
+		{"okno przez północ, teraz po północy", []string{"23:00-01:00"}, at(0, "00:30"), at(0, "00:45"), at(0, "00:30")},
+		{"okno przez północ", []string{"23:00-01:00"}, at(0, "10:00"), at(1, "12:00"), at(0, "23:00")},
+		{"kilka okien", []string{"02:00-03:00", "13:00-14:00"}, at(0, "10:00"), at(0, "20:00"), at(0, "13:00")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := parseRestartWindows(tt.windows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := planRestart(tt.now, tt.deadline, windows); !got.Equal(tt.want) {
+				t.Errorf("planRestart = %v, oczekiwano %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRestartWindows(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    restartWindow
+		wantErr bool
+	}{
+		{spec: "02:00-05:00", want: restartWindow{120, 300}},
+		{spec: "23:30-00:30", want: restartWindow{23*60 + 30, 24*60 + 30}},
+		{spec: "00:00-23:59", want: restartWindow{0, 23*60 + 59}},
+		{spec: "05:00-05:00", wantErr: true},
+		{spec: "24:00-01:00", wantErr: true},
+		{spec: "02:60-03:00", wantErr: true},
+		{spec: "02:00", wantErr: true},
+		{spec: "2-5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRestartWindows([]string{tt.spec})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: błąd = %v, oczekiwano błędu: %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got[0] != tt.want {
+			t.Errorf("%q = %+v, oczekiwano %+v", tt.spec, got[0], tt.want)
+		}
+	}
+}
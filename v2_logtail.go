@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Tożsamość pliku logów - pozwala wykryć rotację przez zmianę nazwy
+type logIdentity struct {
+	dev uint64 // Urządzenie
+	ino uint64 // Numer i-węzła
+}
+
+// Odczytuje urządzenie i i-węzeł z informacji o pliku
+func fileIdentity(info os.FileInfo) logIdentity {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return logIdentity{dev: uint64(st.Dev), ino: st.Ino}
+	}
+	return logIdentity{}
+}
+
+// Wynik odczytu nowych wpisów z logów
+type logRead struct {
+	lines   []string  // Nowe, zakończone linie
+	bytes   int64     // Liczba nowych bajtów (łącznie ze starym plikiem po rotacji)
+	size    int64     // Aktualny rozmiar pliku logów
+	modTime time.Time // Czas modyfikacji pliku (zero gdy plik nie istnieje)
+	rotated bool      // Wykryto rotację lub obcięcie pliku
+}
+
+// Otwiera plik logów i ustawia pozycję odczytu na jego koniec -
+// wcześniejsza zawartość nie jest analizowana
+func (m *Monitor) openLog() error {
+	file, err := os.Open(m.logFile)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	m.closeLog()
+	m.logHandle = file
+	m.logID = fileIdentity(info)
+	m.lastLogSize = info.Size()
+	m.logModTime = info.ModTime()
+	m.partialLine = ""
+	return nil
+}
+
+// Zamyka uchwyt pliku logów
+func (m *Monitor) closeLog() {
+	if m.logHandle != nil {
+		m.logHandle.Close()
+		m.logHandle = nil
+	}
+}
+
+// Czyta nowe wpisy z logów, obsługując rotację (zmiana i-węzła pod tą samą
+// ścieżką lub zniknięcie pliku) oraz obcięcie (copytruncate). Przed
+// przełączeniem na nowy plik dopisane do starego linie są doczytywane.
+func (m *Monitor) tailLog() (logRead, error) {
+	var result logRead
+
+	info, err := os.Stat(m.logFile)
+	if err != nil {
+		if !os.IsNotExist(err) || m.logHandle == nil {
+			return result, err
+		}
+		// Plik przeniesiony, a nowy jeszcze nie powstał - dokończ stary
+		if !m.logMissing {
+			m.logf("Rotacja logów: plik %s zniknął, oczekiwanie na nowy\n", m.logFile)
+			m.logMissing = true
+			result.rotated = true
+		}
+		if err := m.drainLog(&result); err != nil {
+			return result, err
+		}
+		return result, nil
+	}
+	m.logMissing = false
+
+	id := fileIdentity(info)
+	switch {
+	case m.logHandle != nil && id != m.logID:
+		// Rotacja przez zmianę nazwy - doczytaj stary plik i przełącz się
+		if err := m.drainLog(&result); err != nil {
+			return result, err
+		}
+		if m.partialLine != "" {
+			result.lines = append(result.lines, m.partialLine)
+		}
+		m.logf("Rotacja logów: nowy plik (i-węzeł %d -> %d)\n", m.logID.ino, id.ino)
+		m.closeLog()
+		result.rotated = true
+
+	case info.Size() < m.lastLogSize:
+		// Ten sam plik, ale mniejszy - obcięty w miejscu
+		m.logf("Rotacja logów: plik obcięty (%d -> %d bajtów)\n", m.lastLogSize, info.Size())
+		m.lastLogSize = 0
+		m.partialLine = ""
+		result.rotated = true
+	}
+
+	if m.logHandle == nil {
+		file, err := os.Open(m.logFile)
+		if err != nil {
+			return result, err
+		}
+		m.logHandle = file
+		m.logID = id
+		m.lastLogSize = 0
+		m.partialLine = ""
+	}
+
+	result.size = info.Size()
+	result.modTime = info.ModTime()
+	if info.Size() > m.lastLogSize {
+		lines, err := m.readNewLines(m.lastLogSize, info.Size())
+		if err != nil {
+			return result, err
+		}
+		result.lines = append(result.lines, lines...)
+		result.bytes += info.Size() - m.lastLogSize
+		m.lastLogSize = info.Size()
+	}
+	return result, nil
+}
+
+// Doczytuje do końca aktualnie otwarty (np. przeniesiony) plik logów
+func (m *Monitor) drainLog(result *logRead) error {
+	info, err := m.logHandle.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= m.lastLogSize {
+		return nil
+	}
+
+	lines, err := m.readNewLines(m.lastLogSize, info.Size())
+	if err != nil {
+		return err
+	}
+	result.lines = append(result.lines, lines...)
+	result.bytes += info.Size() - m.lastLogSize
+	m.lastLogSize = info.Size()
+	return nil
+}
+
+// Czyta bajty dopisane do logów w zakresie [from, to) i dzieli je na linie.
+// Niezakończona ostatnia linia jest zapamiętywana do następnego odczytu.
+func (m *Monitor) readNewLines(from, to int64) ([]string, error) {
+	// Przy bardzo dużym przyroście czytaj tylko końcówkę
+	if to-from > maxLogReadBytes {
+		from = to - maxLogReadBytes
+		m.partialLine = ""
+	}
+
+	data := make([]byte, to-from)
+	n, err := m.logHandle.ReadAt(data, from)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	lines := strings.Split(m.partialLine+string(data[:n]), "\n")
+	m.partialLine = lines[len(lines)-1]
+	if len(m.partialLine) > maxLogReadBytes {
+		m.partialLine = ""
+	}
+
+	lines = lines[:len(lines)-1]
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	return lines, nil
+}
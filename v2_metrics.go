@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Domyślna ścieżka endpointu metryk
+const defaultMetricsPath = "/metrics"
+
+// Konfiguracja endpointu metryk w formacie Prometheus
+type MetricsConfig struct {
+	Listen string `json:"listen"` // Adres nasłuchu, np. ":9100" (pusty = wyłączone)
+	Path   string `json:"path"`   // Ścieżka endpointu (domyślnie /metrics)
+}
+
+// Uzupełnia brakujące ustawienia metryk
+func (c *MetricsConfig) applyDefaults() {
+	if c.Path == "" {
+		c.Path = defaultMetricsPath
+	}
+}
+
+// Sprawdza poprawność ustawień metryk
+func (c *MetricsConfig) validate() error {
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("metrics: ścieżka %q musi zaczynać się od /", c.Path)
+	}
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			return fmt.Errorf("metrics: nieprawidłowy adres %q: %v", c.Listen, err)
+		}
+	}
+	return nil
+}
+
+// Migawka metryk jednego programu
+type programMetrics struct {
+	name            string
+	up              bool
+	ready           bool
+	uptime          time.Duration
+	restartsByCause map[string]int
+	lastExit        *processExit
+	sinceActivity   time.Duration
+	timeout         time.Duration
+	logBytes        int64
+	lastStop        stopResult
+	stops           int
+	stopsKilled     int
+	usage           resourceUsage
+}
+
+// Zbiera metryki programu
+func (m *Monitor) metrics() programMetrics {
+	ready := m.Ready()
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	pm := programMetrics{
+		name:            m.name,
+		ready:           ready,
+		restartsByCause: make(map[string]int, len(m.restartsByCause)),
+		lastExit:        m.lastExit,
+		timeout:         m.timeout,
+		logBytes:        m.logBytes,
+		lastStop:        m.lastStop,
+		stops:           m.stops,
+		stopsKilled:     m.stopsKilled,
+		usage:           m.usage,
+	}
+	for cause, n := range m.restartsByCause {
+		pm.restartsByCause[cause] = n
+	}
+	if m.process != nil && m.exit != nil && !m.exit.exited() {
+		pm.up = true
+		pm.uptime = time.Since(m.startedAt)
+		pm.sinceActivity = time.Since(m.lastModTime)
+	}
+	return pm
+}
+
+// Zapisuje metryki wszystkich programów w formacie tekstowym Prometheus
+func writeMetrics(buf *bytes.Buffer, programs []programMetrics) {
+	family := func(name, typ, help string, sample func(pm programMetrics)) {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, pm := range programs {
+			sample(pm)
+		}
+	}
+	value := func(name string, pm programMetrics, v float64) {
+		fmt.Fprintf(buf, "%s{program=\"%s\"} %g\n", name, escapeLabel(pm.name), v)
+	}
+
+	family("monitor_restarts_total", "counter", "Liczba restartów programu według przyczyny.", func(pm programMetrics) {
+		causes := make([]string, 0, len(pm.restartsByCause))
+		for cause := range pm.restartsByCause {
+			causes = append(causes, cause)
+		}
+		sort.Strings(causes)
+		for _, cause := range causes {
+			fmt.Fprintf(buf, "monitor_restarts_total{program=\"%s\",reason=\"%s\"} %d\n",
+				escapeLabel(pm.name), escapeLabel(cause), pm.restartsByCause[cause])
+		}
+	})
+	family("monitor_up", "gauge", "Czy proces programu działa (1) czy nie (0).", func(pm programMetrics) {
+		value("monitor_up", pm, boolValue(pm.up))
+	})
+	family("monitor_ready", "gauge", "Czy program jest gotowy według sond readiness.", func(pm programMetrics) {
+		value("monitor_ready", pm, boolValue(pm.ready))
+	})
+	family("monitor_uptime_seconds", "gauge", "Czas działania bieżącego procesu.", func(pm programMetrics) {
+		value("monitor_uptime_seconds", pm, pm.uptime.Seconds())
+	})
+	family("monitor_last_exit_code", "gauge", "Kod wyjścia ostatniego zakończonego procesu (-1 gdy zabity sygnałem).", func(pm programMetrics) {
+		if pm.lastExit != nil {
+			value("monitor_last_exit_code", pm, float64(pm.lastExit.exitCode))
+		}
+	})
+	family("monitor_last_activity_seconds", "gauge", "Sekundy od ostatniej aktywności w logach.", func(pm programMetrics) {
+		if pm.up {
+			value("monitor_last_activity_seconds", pm, pm.sinceActivity.Seconds())
+		}
+	})
+	family("monitor_log_timeout_seconds", "gauge", "Limit ciszy w logach, po którym proces jest restartowany.", func(pm programMetrics) {
+		value("monitor_log_timeout_seconds", pm, pm.timeout.Seconds())
+	})
+	family("monitor_log_bytes_total", "counter", "Bajty logów zapisane przez program.", func(pm programMetrics) {
+		value("monitor_log_bytes_total", pm, float64(pm.logBytes))
+	})
+	family("monitor_rss_bytes", "gauge", "Pamięć rezydentna całego drzewa procesów (strażnik zasobów).", func(pm programMetrics) {
+		if pm.up && !pm.usage.at.IsZero() {
+			value("monitor_rss_bytes", pm, float64(pm.usage.rssBytes))
+		}
+	})
+	family("monitor_cpu_percent", "gauge", "Zużycie CPU drzewa procesów w % jednego rdzenia (strażnik zasobów).", func(pm programMetrics) {
+		if pm.up && pm.usage.cpuValid {
+			value("monitor_cpu_percent", pm, pm.usage.cpuPercent)
+		}
+	})
+	family("monitor_open_fds", "gauge", "Otwarte deskryptory drzewa procesów (strażnik zasobów).", func(pm programMetrics) {
+		if pm.up && !pm.usage.at.IsZero() {
+			value("monitor_open_fds", pm, float64(pm.usage.fds))
+		}
+	})
+	family("monitor_threads", "gauge", "Wątki drzewa procesów (strażnik zasobów).", func(pm programMetrics) {
+		if pm.up && !pm.usage.at.IsZero() {
+			value("monitor_threads", pm, float64(pm.usage.threads))
+		}
+	})
+	family("monitor_stops_total", "counter", "Liczba zatrzymań procesu przez monitor.", func(pm programMetrics) {
+		value("monitor_stops_total", pm, float64(pm.stops))
+	})
+	family("monitor_stop_sigkill_total", "counter", "Liczba zatrzymań, które wymagały SIGKILL.", func(pm programMetrics) {
+		value("monitor_stop_sigkill_total", pm, float64(pm.stopsKilled))
+	})
+	family("monitor_last_stop_duration_seconds", "gauge", "Czas trwania ostatniego zatrzymania procesu.", func(pm programMetrics) {
+		if pm.stops > 0 {
+			value("monitor_last_stop_duration_seconds", pm, pm.lastStop.duration.Seconds())
+		}
+	})
+	family("monitor_last_stop_sigkill", "gauge", "Czy ostatnie zatrzymanie wymagało SIGKILL.", func(pm programMetrics) {
+		if pm.stops > 0 {
+			value("monitor_last_stop_sigkill", pm, boolValue(pm.lastStop.killed))
+		}
+	})
+}
+
+// Escapuje wartość etykiety Prometheus
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// 1 dla true, 0 dla false
+func boolValue(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// Obsługuje żądanie metryk
+func (s *Supervisor) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	monitors := append([]*Monitor(nil), s.monitors...)
+	s.mutex.Unlock()
+
+	programs := make([]programMetrics, 0, len(monitors))
+	for _, m := range monitors {
+		programs = append(programs, m.metrics())
+	}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, programs)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// Uruchamia serwer metryk; zwraca funkcję zatrzymującą serwer
+func (s *Supervisor) startMetrics(cfg MetricsConfig) (func(), error) {
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(cfg.Path, s.serveMetrics)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+
+	fmt.Fprintf(humanOutput, "Metryki: http://%s%s\n", listener.Addr(), cfg.Path)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Domyślne ustawienia powiadomień
+const (
+	defaultNotifyTimeout    = 10 * time.Second
+	defaultNotifyRateLimit  = 10
+	defaultNotifyRatePeriod = time.Hour
+	defaultNotifyDedup      = 5 * time.Minute
+	defaultEmailSubject     = "[monitor] {{.Program}}: {{.Type}}"
+)
+
+// Ile bajtów wyjścia hooka lub odpowiedzi webhooka pokazywać w błędzie
+const maxNotifyErrorOutput = 512
+
+// Liczba prób wysłania webhooka (błąd sieci, status 5xx lub 429)
+const webhookAttempts = 3
+
+// Odstęp przed kolejną próbą webhooka (rośnie z numerem próby)
+var webhookRetryDelay = time.Second
+
+// Webhook HTTP z treścią budowaną z szablonu
+type WebhookConfig struct {
+	URL     string            `json:"url"`     // Adres webhooka
+	Method  string            `json:"method"`  // Metoda HTTP (domyślnie POST)
+	Headers map[string]string `json:"headers"` // Dodatkowe nagłówki, np. Authorization
+	Body    string            `json:"body"`    // Szablon treści JSON (domyślnie zdarzenie jako JSON)
+}
+
+// Lokalny hook - komenda dostaje dane zdarzenia w zmiennych MONITOR_*
+type ExecHookConfig struct {
+	Command string `json:"command"` // Komenda uruchamiana przez sh -c
+}
+
+// Wiadomość e-mail wysyłana przez SMTP
+type EmailConfig struct {
+	Server   string   `json:"server"`   // Serwer SMTP w postaci host:port
+	Username string   `json:"username"` // Login (pusty = bez uwierzytelniania)
+	Password string   `json:"password"` // Hasło
+	From     string   `json:"from"`     // Nadawca
+	To       []string `json:"to"`       // Odbiorcy
+	Subject  string   `json:"subject"`  // Szablon tematu
+	Body     string   `json:"body"`     // Szablon treści (domyślnie opis zdarzenia)
+}
+
+// Konfiguracja pojedynczego kanału powiadomień
+type NotifierConfig struct {
+	Name        string          `json:"name"`         // Nazwa kanału (w komunikatach monitora)
+	Webhook     *WebhookConfig  `json:"webhook"`      // Webhook HTTP
+	Exec        *ExecHookConfig `json:"exec"`         // Lokalny hook
+	Email       *EmailConfig    `json:"email"`        // E-mail przez SMTP
+	Events      []string        `json:"events"`       // Rodzaje zdarzeń (domyślnie restart-requested)
+	Programs    []string        `json:"programs"`     // Tylko te programy (pusta lista = wszystkie)
+	Timeout     Duration        `json:"timeout"`      // Limit czasu wysyłki (domyślnie 10s)
+	RateLimit   int             `json:"rate_limit"`   // Maksymalna liczba powiadomień w rate_period (domyślnie 10)
+	RatePeriod  Duration        `json:"rate_period"`  // Okno limitu (domyślnie 1h)
+	DedupWindow *Duration       `json:"dedup_window"` // Okno pomijania powtórzeń tego samego zdarzenia (domyślnie 5m, 0 = wyłączone)
+}
+
+// Uzupełnia brakujące ustawienia kanału
+func (c *NotifierConfig) applyDefaults() {
+	if len(c.Events) == 0 {
+		c.Events = []string{eventRestartRequested}
+	}
+	if c.Timeout.Duration == 0 {
+		c.Timeout.Duration = defaultNotifyTimeout
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = defaultNotifyRateLimit
+	}
+	if c.RatePeriod.Duration == 0 {
+		c.RatePeriod.Duration = defaultNotifyRatePeriod
+	}
+	if c.DedupWindow == nil {
+		c.DedupWindow = &Duration{defaultNotifyDedup}
+	}
+	if c.Webhook != nil && c.Webhook.Method == "" {
+		c.Webhook.Method = http.MethodPost
+	}
+	if c.Email != nil && c.Email.Subject == "" {
+		c.Email.Subject = defaultEmailSubject
+	}
+	if c.Name == "" {
+		switch {
+		case c.Webhook != nil:
+			c.Name = "webhook " + c.Webhook.URL
+		case c.Exec != nil:
+			c.Name = "exec " + c.Exec.Command
+		case c.Email != nil:
+			c.Name = "email " + strings.Join(c.Email.To, ",")
+		}
+	}
+}
+
+// Sprawdza poprawność konfiguracji kanału
+func (c *NotifierConfig) validate() error {
+	count := 0
+	if c.Webhook != nil {
+		count++
+		if c.Webhook.URL == "" {
+			return fmt.Errorf("webhook: brak url")
+		}
+		if _, err := parseNotifyTemplate("body", c.Webhook.Body); err != nil {
+			return fmt.Errorf("webhook: %v", err)
+		}
+	}
+	if c.Exec != nil {
+		count++
+		if c.Exec.Command == "" {
+			return fmt.Errorf("exec: brak command")
+		}
+	}
+	if c.Email != nil {
+		count++
+		if _, _, err := net.SplitHostPort(c.Email.Server); err != nil {
+			return fmt.Errorf("email: nieprawidłowy server %q (oczekiwano host:port)", c.Email.Server)
+		}
+		if c.Email.From == "" || len(c.Email.To) == 0 {
+			return fmt.Errorf("email: wymagane from i to")
+		}
+		for _, t := range []string{c.Email.Subject, c.Email.Body} {
+			if _, err := parseNotifyTemplate("email", t); err != nil {
+				return fmt.Errorf("email: %v", err)
+			}
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("wymagany dokładnie jeden z typów: webhook, exec, email")
+	}
+
+	for _, e := range c.Events {
+		if !isEventType(e) {
+			return fmt.Errorf("nieznany rodzaj zdarzenia %q", e)
+		}
+	}
+	if c.Timeout.Duration <= 0 || c.RateLimit < 1 || c.RatePeriod.Duration <= 0 {
+		return fmt.Errorf("timeout, rate_limit i rate_period muszą być dodatnie")
+	}
+	if c.DedupWindow != nil && c.DedupWindow.Duration < 0 {
+		return fmt.Errorf("dedup_window nie może być ujemny")
+	}
+	return nil
+}
+
+// Parsuje szablon powiadomienia. Funkcja json zwraca wartość zakodowaną
+// jako JSON - do bezpiecznego wstawiania tekstu w treść webhooka.
+func parseNotifyTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+}
+
+// Dane przekazywane do szablonów i hooków
+type notification struct {
+	Event
+	Suppressed int    `json:"suppressed,omitempty"` // Pominięte powiadomienia od ostatniej wysyłki
+	Host       string `json:"host"`                 // Nazwa hosta monitora
+}
+
+// Kanał powiadomień z limitem i pomijaniem powtórzeń. Zdarzenia obsługuje
+// jedna gorutyna, więc stan nie wymaga blokad.
+type notifier struct {
+	cfg      NotifierConfig
+	body     *template.Template   // Treść webhooka lub e-maila (nil = domyślna)
+	subject  *template.Template   // Temat e-maila
+	sent     []time.Time          // Czasy wysyłek w oknie rate_period
+	lastSeen map[string]time.Time // Ostatnie wystąpienie zdarzenia według klucza
+	skipped  int                  // Pominięte od ostatniej wysyłki
+}
+
+// Tworzy kanał powiadomień ze sprawdzonej konfiguracji
+func newNotifier(cfg NotifierConfig) *notifier {
+	n := &notifier{cfg: cfg, lastSeen: make(map[string]time.Time)}
+	switch {
+	case cfg.Webhook != nil && cfg.Webhook.Body != "":
+		n.body, _ = parseNotifyTemplate("body", cfg.Webhook.Body)
+	case cfg.Email != nil:
+		n.subject, _ = parseNotifyTemplate("subject", cfg.Email.Subject)
+		if cfg.Email.Body != "" {
+			n.body, _ = parseNotifyTemplate("body", cfg.Email.Body)
+		}
+	}
+	return n
+}
+
+// Czy kanał obsługuje zdarzenie
+func (n *notifier) wants(e Event) bool {
+	if len(n.cfg.Programs) > 0 && !containsString(n.cfg.Programs, e.Program) {
+		return false
+	}
+	return containsString(n.cfg.Events, e.Type)
+}
+
+// Sprawdza limit i powtórzenia - zwraca false, gdy zdarzenie należy pominąć
+func (n *notifier) admit(e Event) bool {
+	now := e.Time
+
+	// To samo zdarzenie (bez zmiennego opisu) w oknie deduplikacji
+	key := strings.Join([]string{e.Program, e.Type, e.Cause, e.Probe, e.Signal}, "\x00")
+	for k, t := range n.lastSeen {
+		if now.Sub(t) >= n.cfg.DedupWindow.Duration {
+			delete(n.lastSeen, k)
+		}
+	}
+	if _, seen := n.lastSeen[key]; seen {
+		n.skipped++
+		return false
+	}
+
+	// Okno przesuwne rate_period
+	kept := n.sent[:0]
+	for _, t := range n.sent {
+		if now.Sub(t) < n.cfg.RatePeriod.Duration {
+			kept = append(kept, t)
+		}
+	}
+	n.sent = kept
+	if len(n.sent) >= n.cfg.RateLimit {
+		n.skipped++
+		return false
+	}
+	n.sent = append(n.sent, now)
+	n.lastSeen[key] = now
+	return true
+}
+
+// Obsługuje zdarzenia aż do zamknięcia kanału
+func (n *notifier) run(events <-chan Event) {
+	host, _ := os.Hostname()
+	for e := range events {
+		if !n.wants(e) || !n.admit(e) {
+			continue
+		}
+		data := notification{Event: e, Suppressed: n.skipped, Host: host}
+		n.skipped = 0
+
+		ctx, cancel := context.WithTimeout(context.Background(), n.cfg.Timeout.Duration)
+		err := n.deliver(ctx, data)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(humanOutput, "Powiadomienie %s nie zostało wysłane: %v\n", n.cfg.Name, err)
+		}
+	}
+}
+
+// Wysyła powiadomienie właściwym kanałem
+func (n *notifier) deliver(ctx context.Context, data notification) error {
+	switch {
+	case n.cfg.Webhook != nil:
+		return n.sendWebhook(ctx, data)
+	case n.cfg.Exec != nil:
+		return n.runHook(ctx, data)
+	default:
+		return n.sendEmail(ctx, data)
+	}
+}
+
+// Wykonuje szablon; bez szablonu zwraca wynik fallback
+func render(t *template.Template, data notification, fallback func() []byte) ([]byte, error) {
+	if t == nil {
+		return fallback(), nil
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("błąd szablonu: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Skraca wyjście pokazywane w komunikacie błędu
+func truncateOutput(data []byte) string {
+	s := strings.TrimSpace(string(data))
+	if len(s) > maxNotifyErrorOutput {
+		s = s[:maxNotifyErrorOutput] + "..."
+	}
+	return s
+}
+
+// Wysyła webhook HTTP, ponawiając próbę po błędach przejściowych
+func (n *notifier) sendWebhook(ctx context.Context, data notification) error {
+	body, err := render(n.body, data, func() []byte {
+		b, _ := json.Marshal(data)
+		return b
+	})
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := n.postWebhook(ctx, body)
+		if err == nil || !retry || attempt == webhookAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * webhookRetryDelay):
+		}
+	}
+}
+
+// Pojedyncza próba wysłania webhooka. Zwraca też, czy błąd jest przejściowy
+// (sieć, przeciążony serwer) i warto spróbować ponownie.
+func (n *notifier) postWebhook(ctx context.Context, body []byte) (bool, error) {
+	cfg := n.cfg.Webhook
+	req, err := http.NewRequestWithContext(ctx, cfg.Method, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		out, _ := io.ReadAll(io.LimitReader(resp.Body, maxNotifyErrorOutput))
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("status HTTP %d: %s", resp.StatusCode, truncateOutput(out))
+	}
+	return false, nil
+}
+
+// Uruchamia lokalny hook z danymi zdarzenia w zmiennych środowiskowych.
+// Po przekroczeniu limitu czasu zabijana jest cała grupa procesów hooka.
+func (n *notifier) runHook(ctx context.Context, data notification) error {
+	cmd := groupCommand(ctx, "sh", "-c", n.cfg.Exec.Command)
+	cmd.Env = append(os.Environ(), notifyEnv(data)...)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return fmt.Errorf("przekroczono limit czasu %v", n.cfg.Timeout.Duration)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, truncateOutput(out))
+	}
+	return nil
+}
+
+// Zmienne MONITOR_* opisujące zdarzenie
+func notifyEnv(data notification) []string {
+	e := data.Event
+	full, _ := json.Marshal(data)
+	env := []string{
+		"MONITOR_EVENT=" + e.Type,
+		"MONITOR_PROGRAM=" + e.Program,
+		"MONITOR_TIME=" + e.Time.Format(time.RFC3339),
+		"MONITOR_REASON=" + e.Reason,
+		"MONITOR_CAUSE=" + e.Cause,
+		"MONITOR_SIGNAL=" + e.Signal,
+		"MONITOR_PROBE=" + e.Probe,
+		fmt.Sprintf("MONITOR_SUPPRESSED=%d", data.Suppressed),
+		"MONITOR_EVENT_JSON=" + string(full),
+	}
+	if e.PID != 0 {
+		env = append(env, fmt.Sprintf("MONITOR_PID=%d", e.PID))
+	}
+	if e.ExitCode != nil {
+		env = append(env, fmt.Sprintf("MONITOR_EXIT_CODE=%d", *e.ExitCode))
+	}
+	return env
+}
+
+// Wysyła e-mail przez SMTP (STARTTLS, gdy serwer go oferuje)
+func (n *notifier) sendEmail(ctx context.Context, data notification) error {
+	cfg := n.cfg.Email
+	subject, err := render(n.subject, data, nil)
+	if err != nil {
+		return err
+	}
+	body, err := render(n.body, data, func() []byte {
+		text := formatEvent(data.Event) + "\n"
+		if data.Suppressed > 0 {
+			text += fmt.Sprintf("\nPominięte wcześniej powiadomienia: %d\n", data.Suppressed)
+		}
+		return []byte(text)
+	})
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(string(subject))))
+	fmt.Fprintf(&msg, "Date: %s\r\n", data.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(string(body), "\n", "\r\n"))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.Server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := net.SplitHostPort(cfg.Server)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Uruchamia kanały powiadomień; zwraca funkcję, która je zatrzymuje
+// po wysłaniu zaległych powiadomień
+func (s *Supervisor) startNotifiers(cfgs []NotifierConfig) func() {
+	var wg sync.WaitGroup
+	var subs []chan Event
+	for _, cfg := range cfgs {
+		n := newNotifier(cfg)
+		events := s.events.subscribe()
+		subs = append(subs, events)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.run(events)
+		}()
+		fmt.Fprintf(humanOutput, "Powiadomienia: %s (%s)\n", cfg.Name, strings.Join(cfg.Events, ", "))
+	}
+
+	return func() {
+		for _, events := range subs {
+			s.events.unsubscribe(events)
+			close(events)
+		}
+		wg.Wait()
+	}
+}
+
+// Czy lista zawiera tekst
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Kanał powiadomień z domyślnymi ustawieniami uzupełnionymi jak przy wczytaniu konfiguracji
+func testNotifier(t *testing.T, cfg NotifierConfig) *notifier {
+	t.Helper()
+	cfg.applyDefaults()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	return newNotifier(cfg)
+}
+
+// Skraca odstęp między próbami webhooka na czas testu
+func fastWebhookRetries(t *testing.T) {
+	old := webhookRetryDelay
+	webhookRetryDelay = time.Millisecond
+	t.Cleanup(func() { webhookRetryDelay = old })
+}
+
+// Serwer HTTP zapamiętujący treści żądań i odpowiadający kolejnymi statusami
+// (po wyczerpaniu listy - 200)
+type webhookStub struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (s *webhookStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, http.StatusText(status))
+}
+
+func (s *webhookStub) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func testEvent(at time.Time) Event {
+	return Event{
+		Time:    at,
+		Program: "app",
+		Type:    eventRestartRequested,
+		PID:     1234,
+		Cause:   causeLogTimeout,
+		Reason:  `brak aktywności w logach przez 5m0s ("cisza")`,
+	}
+}
+
+func TestWebhookPayload(t *testing.T) {
+	stub := &webhookStub{}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		body  string
+		check func(t *testing.T, payload map[string]interface{})
+	}{
+		{
+			name: "domyślna treść",
+			check: func(t *testing.T, p map[string]interface{}) {
+				if p["program"] != "app" || p["type"] != eventRestartRequested || p["cause"] != causeLogTimeout {
+					t.Errorf("pola zdarzenia: %v", p)
+				}
+				if p["pid"] != float64(1234) {
+					t.Errorf("pid = %v, oczekiwano 1234", p["pid"])
+				}
+				if _, ok := p["host"]; !ok {
+					t.Errorf("brak pola host: %v", p)
+				}
+			},
+		},
+		{
+			name: "szablon z funkcją json",
+			body: `{"text": {{json .Reason}}, "who": "{{.Program}}/{{.PID}}"}`,
+			check: func(t *testing.T, p map[string]interface{}) {
+				if p["text"] != testEvent(time.Time{}).Reason {
+					t.Errorf("text = %q", p["text"])
+				}
+				if p["who"] != "app/1234" {
+					t.Errorf("who = %q", p["who"])
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub.mu.Lock()
+			stub.requests, stub.bodies = nil, nil
+			stub.mu.Unlock()
+
+			n := testNotifier(t, NotifierConfig{Webhook: &WebhookConfig{
+				URL:     srv.URL + "/hook",
+				Headers: map[string]string{"Authorization": "Bearer sekret"},
+				Body:    tt.body,
+			}})
+			if err := n.deliver(context.Background(), notification{Event: testEvent(time.Now())}); err != nil {
+				t.Fatalf("deliver: %v", err)
+			}
+			if stub.count() != 1 {
+				t.Fatalf("liczba żądań = %d, oczekiwano 1", stub.count())
+			}
+			r := stub.requests[0]
+			if r.Method != http.MethodPost || r.URL.Path != "/hook" {
+				t.Errorf("żądanie %s %s", r.Method, r.URL.Path)
+			}
+			if got := r.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := r.Header.Get("Authorization"); got != "Bearer sekret" {
+				t.Errorf("Authorization = %q", got)
+			}
+			var payload map[string]interface{}
+			if err := json.Unmarshal(stub.bodies[0], &payload); err != nil {
+				t.Fatalf("treść nie jest JSON: %v: %s", err, stub.bodies[0])
+			}
+			tt.check(t, payload)
+		})
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	fastWebhookRetries(t)
+
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		wantErr  bool
+	}{
+		{"sukces za pierwszym razem", nil, 1, false},
+		{"5xx, potem sukces", []int{500, 502}, 3, false},
+		{"429, potem sukces", []int{429}, 2, false},
+		{"limit prób", []int{503, 503, 503, 503}, webhookAttempts, true},
+		{"4xx bez ponawiania", []int{400}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &webhookStub{statuses: append([]int(nil), tt.statuses...)}
+			srv := httptest.NewServer(stub)
+			defer srv.Close()
+
+			n := testNotifier(t, NotifierConfig{Webhook: &WebhookConfig{URL: srv.URL}})
+			err := n.deliver(context.Background(), notification{Event: testEvent(time.Now())})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("błąd = %v, oczekiwano błędu: %v", err, tt.wantErr)
+			}
+			if stub.count() != tt.attempts {
+				t.Errorf("liczba prób = %d, oczekiwano %d", stub.count(), tt.attempts)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryNetworkError(t *testing.T) {
+	fastWebhookRetries(t)
+
+	// Zamknięty port - każda próba kończy się błędem połączenia
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + ln.Addr().String()
+	ln.Close()
+
+	n := testNotifier(t, NotifierConfig{Webhook: &WebhookConfig{URL: url}})
+	if err := n.deliver(context.Background(), notification{Event: testEvent(time.Now())}); err == nil {
+		t.Fatal("oczekiwano błędu połączenia")
+	}
+}
+
+func TestNotifierDedupWindow(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	other := testEvent(t0.Add(3 * time.Second))
+	other.Cause = causeProbe
+
+	tests := []struct {
+		name           string
+		window         time.Duration
+		wantRequests   int
+		wantSuppressed float64 // Pole suppressed ostatniego powiadomienia
+	}{
+		{"okno 0 wyłącza deduplikację", 0, 4, 0},
+		{"powtórzenia w oknie pomijane", 5 * time.Minute, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &webhookStub{}
+			srv := httptest.NewServer(stub)
+			defer srv.Close()
+
+			n := testNotifier(t, NotifierConfig{
+				Webhook:     &WebhookConfig{URL: srv.URL},
+				DedupWindow: &Duration{tt.window},
+			})
+			events := make(chan Event, 4)
+			for i := 0; i < 3; i++ {
+				events <- testEvent(t0.Add(time.Duration(i) * time.Second))
+			}
+			events <- other
+			close(events)
+			n.run(events)
+
+			if stub.count() != tt.wantRequests {
+				t.Fatalf("liczba powiadomień = %d, oczekiwano %d", stub.count(), tt.wantRequests)
+			}
+			var last map[string]interface{}
+			json.Unmarshal(stub.bodies[len(stub.bodies)-1], &last)
+			got, _ := last["suppressed"].(float64)
+			if got != tt.wantSuppressed {
+				t.Errorf("suppressed = %v, oczekiwano %v", got, tt.wantSuppressed)
+			}
+		})
+	}
+}
+
+func TestNotifierDedupExpires(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	n := testNotifier(t, NotifierConfig{
+		Exec:        &ExecHookConfig{Command: "true"},
+		DedupWindow: &Duration{time.Minute},
+	})
+
+	tests := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{59 * time.Second, false},
+		{time.Minute, true}, // Dokładnie na granicy okna zdarzenie jest wysyłane ponownie
+		{time.Minute + 30*time.Second, false},
+	}
+	for _, tt := range tests {
+		if got := n.admit(testEvent(t0.Add(tt.at))); got != tt.want {
+			t.Errorf("admit po %v = %v, oczekiwano %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestNotifierRateLimit(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	n := testNotifier(t, NotifierConfig{
+		Exec:        &ExecHookConfig{Command: "true"},
+		RateLimit:   2,
+		RatePeriod:  Duration{time.Hour},
+		DedupWindow: &Duration{0},
+	})
+	for i, want := range []bool{true, true, false} {
+		if got := n.admit(testEvent(t0.Add(time.Duration(i) * time.Minute))); got != want {
+			t.Errorf("zdarzenie %d: admit = %v, oczekiwano %v", i, got, want)
+		}
+	}
+	if !n.admit(testEvent(t0.Add(time.Hour))) {
+		t.Error("po upływie rate_period zdarzenie powinno zostać wysłane")
+	}
+}
+
+// Minimalny serwer SMTP zapamiętujący kopertę i treść jednej wiadomości
+type smtpStub struct {
+	ln   net.Listener
+	from string
+	rcpt []string
+	data string
+	done chan struct{}
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpStub) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from = smtpAddress(line)
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.rcpt = append(s.rcpt, smtpAddress(line))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// Adres w nawiasach ostrych z polecenia MAIL FROM lub RCPT TO
+func smtpAddress(line string) string {
+	start := strings.IndexByte(line, '<')
+	end := strings.IndexByte(line, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestEmailNotifier(t *testing.T) {
+	stub := newSMTPStub(t)
+	n := testNotifier(t, NotifierConfig{Email: &EmailConfig{
+		Server: stub.ln.Addr().String(),
+		From:   "monitor@example.com",
+		To:     []string{"ops@example.com", "dev@example.com"},
+	}})
+
+	data := notification{Event: testEvent(time.Now()), Suppressed: 3}
+	if err := n.deliver(context.Background(), data); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	<-stub.done
+
+	if stub.from != "monitor@example.com" {
+		t.Errorf("MAIL FROM = %q", stub.from)
+	}
+	if strings.Join(stub.rcpt, ",") != "ops@example.com,dev@example.com" {
+		t.Errorf("RCPT TO = %v", stub.rcpt)
+	}
+	for _, want := range []string{
+		"From: monitor@example.com\r\n",
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: [monitor] app: restart-requested\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\n" + formatEvent(data.Event) + "\r\n",
+		"Pominięte wcześniej powiadomienia: 3\r\n",
+	} {
+		if !strings.Contains(stub.data, want) {
+			t.Errorf("wiadomość nie zawiera %q:\n%s", want, stub.data)
+		}
+	}
+}
+
+func TestExecNotifierEnv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env.txt")
+	n := testNotifier(t, NotifierConfig{Exec: &ExecHookConfig{
+		Command: `echo "$MONITOR_EVENT|$MONITOR_PROGRAM|$MONITOR_PID|$MONITOR_CAUSE|$MONITOR_SUPPRESSED" > ` + out,
+	}})
+	if err := n.deliver(context.Background(), notification{Event: testEvent(time.Now()), Suppressed: 2}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	want := "restart-requested|app|1234|" + causeLogTimeout + "|2\n"
+	if string(got) != want {
+		t.Errorf("zmienne hooka = %q, oczekiwano %q", got, want)
+	}
+}
+
+func TestExecNotifierTimeoutKillsGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	n := testNotifier(t, NotifierConfig{
+		Exec:    &ExecHookConfig{Command: "sleep 30 & echo $! > " + pidFile + "; wait"},
+		Timeout: Duration{300 * time.Millisecond},
+	})
+
+	// Limit czasu nakłada run - tu tak samo
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), n.cfg.Timeout.Duration)
+	defer cancel()
+	err := n.deliver(ctx, notification{Event: testEvent(start)})
+	if err == nil || !strings.Contains(err.Error(), "przekroczono limit czasu") {
+		t.Fatalf("błąd = %v, oczekiwano przekroczenia limitu czasu", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("hook zakończony po %v - potomek trzymał wyjście", elapsed)
+	}
+
+	data, _ := os.ReadFile(pidFile)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("brak PID potomka hooka: %q", data)
+	}
+	// Zabity potomek może przez chwilę pozostać procesem zombie
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p, err := readProc(pid)
+		if err != nil || p.state == 'Z' {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("potomek hooka (PID %d) działa po przekroczeniu limitu czasu", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Domyślne ustawienia przechwytywania wyjścia procesu
+const (
+	defaultOutputMaxSizeMB  = 100
+	defaultOutputMaxBackups = 5
+)
+
+// Maksymalna długość linii wyjścia - dłuższe są dzielone
+const maxOutputLineBytes = 64 << 10
+
+// Maksymalna liczba linii buforowanych między sprawdzeniami
+const maxBufferedLines = 10000
+
+// Format znacznika czasu dopisywanego do linii wyjścia
+const outputTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Ustawienia przechwytywania stdout/stderr procesu do pliku logów
+type OutputConfig struct {
+	Capture    bool     `json:"capture"`     // Monitor sam zapisuje wyjście procesu do log_file
+	MaxSizeMB  int64    `json:"max_size_mb"` // Rotacja po przekroczeniu rozmiaru (domyślnie 100 MB)
+	MaxAge     Duration `json:"max_age"`     // Rotacja po upływie czasu (0 = wyłączona)
+	MaxBackups int      `json:"max_backups"` // Liczba zachowanych starych segmentów (domyślnie 5)
+	Timestamps bool     `json:"timestamps"`  // Dopisywanie znacznika czasu do każdej linii
+	Compress   bool     `json:"compress"`    // Kompresja gzip starych segmentów
+}
+
+// Uzupełnia brakujące ustawienia przechwytywania wyjścia
+func (o *OutputConfig) applyDefaults() {
+	if o.MaxSizeMB == 0 {
+		o.MaxSizeMB = defaultOutputMaxSizeMB
+	}
+	if o.MaxBackups == 0 {
+		o.MaxBackups = defaultOutputMaxBackups
+	}
+}
+
+// Sprawdza poprawność ustawień przechwytywania wyjścia
+func (o *OutputConfig) validate() error {
+	if o.MaxSizeMB < 0 || o.MaxBackups < 0 || o.MaxAge.Duration < 0 {
+		return fmt.Errorf("output: max_size_mb, max_backups i max_age nie mogą być ujemne")
+	}
+	return nil
+}
+
+// Zapisuje wyjście procesu do pliku logów z rotacją i jednocześnie
+// buforuje nowe linie jako sygnał aktywności dla checkLogs
+type outputCapture struct {
+	mu       sync.Mutex
+	cfg      OutputConfig
+	path     string                                   // Ścieżka do pliku logów
+	file     *os.File                                 // Bieżący segment
+	size     int64                                    // Rozmiar bieżącego segmentu
+	openedAt time.Time                                // Kiedy otwarto bieżący segment
+	lines    []string                                 // Linie od ostatniego sprawdzenia
+	bytes    int64                                    // Bajty od ostatniego sprawdzenia
+	failed   bool                                     // Czy zgłoszono już błąd zapisu
+	logf     func(format string, args ...interface{}) // Komunikaty monitora
+}
+
+// Otwiera (lub tworzy) plik logów do dopisywania
+func newOutputCapture(path string, cfg OutputConfig, logf func(string, ...interface{})) (*outputCapture, error) {
+	oc := &outputCapture{cfg: cfg, path: path, logf: logf}
+	if err := oc.open(); err != nil {
+		return nil, err
+	}
+	return oc, nil
+}
+
+// Otwiera bieżący segment
+func (oc *outputCapture) open() error {
+	file, err := os.OpenFile(oc.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	oc.file = file
+	oc.size = info.Size()
+	oc.openedAt = time.Now()
+	return nil
+}
+
+// Tworzy strumień procesu (stdout lub stderr): potok, którego koniec do
+// zapisu dostaje proces, oraz writer dzielący odczytane dane na linie.
+// Proces dostaje *os.File, więc Wait nie czeka na potomków, którzy
+// odziedziczyli wyjście - ich linie są zapisywane, dopóki potok jest otwarty.
+func (oc *outputCapture) newStream() (*os.File, *lineWriter, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	lw := &lineWriter{oc: oc}
+	go func() {
+		io.Copy(lw, r)
+		r.Close()
+		lw.flush()
+	}()
+	return w, lw, nil
+}
+
+// Zapisuje pojedynczą linię, w razie potrzeby rotując plik
+func (oc *outputCapture) writeLine(line []byte) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	var buf bytes.Buffer
+	if oc.cfg.Timestamps {
+		buf.WriteString(time.Now().Format(outputTimestampFormat))
+		buf.WriteByte(' ')
+	}
+	buf.Write(line)
+	buf.WriteByte('\n')
+
+	if oc.needsRotation(int64(buf.Len())) {
+		if err := oc.rotate(); err != nil {
+			oc.logf("Błąd rotacji pliku logów: %v\n", err)
+		}
+	}
+
+	if oc.file != nil {
+		n, err := oc.file.Write(buf.Bytes())
+		oc.size += int64(n)
+		if err != nil && !oc.failed {
+			oc.logf("Błąd zapisu do pliku logów: %v\n", err)
+			oc.failed = true
+		}
+	}
+
+	// Bufor aktywności - przy zalewie linii zostają najnowsze
+	oc.lines = append(oc.lines, string(line))
+	if len(oc.lines) > maxBufferedLines {
+		oc.lines = oc.lines[len(oc.lines)-maxBufferedLines:]
+	}
+	oc.bytes += int64(buf.Len())
+}
+
+// Czy zapis kolejnych n bajtów wymaga rotacji segmentu
+func (oc *outputCapture) needsRotation(n int64) bool {
+	if oc.size > 0 && oc.size+n > oc.cfg.MaxSizeMB<<20 {
+		return true
+	}
+	return oc.cfg.MaxAge.Duration > 0 && time.Since(oc.openedAt) > oc.cfg.MaxAge.Duration
+}
+
+// Nazwa n-tego starego segmentu (plik.1, plik.2.gz, ...)
+func (oc *outputCapture) backupName(n int) string {
+	name := fmt.Sprintf("%s.%d", oc.path, n)
+	if oc.cfg.Compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// Przesuwa stare segmenty, archiwizuje bieżący i otwiera nowy plik
+func (oc *outputCapture) rotate() error {
+	if oc.file != nil {
+		oc.file.Close()
+		oc.file = nil
+	}
+
+	os.Remove(oc.backupName(oc.cfg.MaxBackups))
+	for n := oc.cfg.MaxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(oc.backupName(n), oc.backupName(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if oc.cfg.MaxBackups > 0 {
+		if oc.cfg.Compress {
+			if err := compressFile(oc.path, oc.backupName(1)); err != nil {
+				return err
+			}
+			os.Remove(oc.path)
+		} else if err := os.Rename(oc.path, oc.backupName(1)); err != nil {
+			return err
+		}
+	} else {
+		os.Remove(oc.path)
+	}
+
+	oc.failed = false
+	return oc.open()
+}
+
+// Kompresuje plik src do dst (gzip)
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Zwraca i czyści linie zebrane od ostatniego sprawdzenia
+func (oc *outputCapture) take() logRead {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	read := logRead{lines: oc.lines, bytes: oc.bytes, size: oc.size}
+	oc.lines = nil
+	oc.bytes = 0
+	return read
+}
+
+// Zamyka plik logów
+func (oc *outputCapture) close() {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	if oc.file != nil {
+		oc.file.Close()
+		oc.file = nil
+	}
+}
+
+// Dzieli strumień wyjścia procesu na linie przekazywane do outputCapture
+type lineWriter struct {
+	mu  sync.Mutex
+	oc  *outputCapture
+	buf []byte // Niezakończona linia
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.oc.writeLine(bytes.TrimSuffix(w.buf[:i], []byte("\r")))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxOutputLineBytes {
+		w.oc.writeLine(w.buf)
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Zapisuje niezakończoną linię (np. po zakończeniu procesu)
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.oc.writeLine(w.buf)
+		w.buf = nil
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Akcje wykonywane po przekroczeniu progu wzorca błędu
+const (
+	errorActionRestart = "restart" // Restart procesu
+	errorActionAlert   = "alert"   // Tylko ostrzeżenie w logach monitora
+)
+
+// Domyślne okno zliczania dopasowań wzorca błędu
+const defaultErrorWindow = 60 * time.Second
+
+// Wzorzec błędu z progiem N dopasowań w oknie czasowym
+type ErrorPatternConfig struct {
+	Pattern   string   `json:"pattern"`   // Wyrażenie regularne
+	Threshold int      `json:"threshold"` // Liczba dopasowań wyzwalająca akcję (domyślnie 1)
+	Window    Duration `json:"window"`    // Okno zliczania dopasowań (domyślnie 60s)
+	Action    string   `json:"action"`    // "restart" (domyślnie) lub "alert"
+}
+
+// Uzupełnia brakujące ustawienia wzorca błędu
+func (e *ErrorPatternConfig) applyDefaults() {
+	if e.Threshold == 0 {
+		e.Threshold = 1
+	}
+	if e.Window.Duration == 0 {
+		e.Window.Duration = defaultErrorWindow
+	}
+	if e.Action == "" {
+		e.Action = errorActionRestart
+	}
+}
+
+// Wzorzec błędu wraz z historią dopasowań
+type errorRule struct {
+	cfg  ErrorPatternConfig
+	re   *regexp.Regexp
+	hits []time.Time // Czasy dopasowań w bieżącym oknie
+}
+
+// Rejestruje dopasowanie i zwraca true gdy w oknie osiągnięto próg.
+// Po osiągnięciu progu historia jest czyszczona.
+func (r *errorRule) observe(now time.Time) bool {
+	cutoff := now.Add(-r.cfg.Window.Duration)
+	kept := r.hits[:0]
+	for _, t := range r.hits {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	r.hits = append(kept, now)
+
+	if len(r.hits) >= r.cfg.Threshold {
+		r.hits = r.hits[:0]
+		return true
+	}
+	return false
+}
+
+// Wzorzec błędu, który przekroczył próg
+type errorMatch struct {
+	rule *errorRule
+	line string // Linia, która dopełniła próg
+}
+
+// Opis dopasowania używany jako powód restartu
+func (em errorMatch) reason() string {
+	return fmt.Sprintf("wzorzec błędu %q (%dx w %v): %s",
+		em.rule.cfg.Pattern, em.rule.cfg.Threshold, em.rule.cfg.Window.Duration, em.line)
+}
+
+// Dopasowuje linie logów do wzorców heartbeatu i wzorców ignorowanych
+type logMatcher struct {
+	heartbeat []*regexp.Regexp // Linie uznawane za oznakę życia
+	ignore    []*regexp.Regexp // Linie, które nigdy nie liczą się jako aktywność
+	errors    []*errorRule     // Wzorce błędów wyzwalające restart lub alert
+}
+
+// Kompiluje listę wyrażeń regularnych
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("nieprawidłowy wzorzec %q: %v", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Tworzy matcher na podstawie konfiguracji programu
+func newLogMatcher(cfg ProgramConfig) (*logMatcher, error) {
+	heartbeat, err := compilePatterns(cfg.HeartbeatPatterns)
+	if err != nil {
+		return nil, fmt.Errorf("heartbeat_patterns: %v", err)
+	}
+	ignore, err := compilePatterns(cfg.IgnorePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("ignore_patterns: %v", err)
+	}
+
+	var rules []*errorRule
+	for i, e := range cfg.ErrorPatterns {
+		re, err := regexp.Compile(e.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error_patterns[%d]: nieprawidłowy wzorzec %q: %v", i, e.Pattern, err)
+		}
+		e.applyDefaults()
+		if e.Threshold < 0 || e.Window.Duration < 0 {
+			return nil, fmt.Errorf("error_patterns[%d]: próg i okno nie mogą być ujemne", i)
+		}
+		if e.Action != errorActionRestart && e.Action != errorActionAlert {
+			return nil, fmt.Errorf("error_patterns[%d]: nieznana akcja %q (dozwolone: %s, %s)",
+				i, e.Action, errorActionRestart, errorActionAlert)
+		}
+		rules = append(rules, &errorRule{cfg: e, re: re})
+	}
+
+	return &logMatcher{heartbeat: heartbeat, ignore: ignore, errors: rules}, nil
+}
+
+// Czy aktywność jest oceniana po treści logów (a nie tylko rozmiarze i mtime)
+func (lm *logMatcher) contentAware() bool {
+	return len(lm.heartbeat) > 0 || len(lm.ignore) > 0
+}
+
+// Sprawdza czy linia jest heartbeatem: nie pasuje do żadnego wzorca
+// ignorowanego i pasuje do wzorca heartbeatu (lub żadnego nie skonfigurowano)
+func (lm *logMatcher) isHeartbeat(line string) bool {
+	if line == "" {
+		return false
+	}
+	for _, re := range lm.ignore {
+		if re.MatchString(line) {
+			return false
+		}
+	}
+	if len(lm.heartbeat) == 0 {
+		return true
+	}
+	for _, re := range lm.heartbeat {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Liczy linie będące heartbeatem
+func (lm *logMatcher) countHeartbeats(lines []string) int {
+	count := 0
+	for _, line := range lines {
+		if lm.isHeartbeat(line) {
+			count++
+		}
+	}
+	return count
+}
+
+// Sprawdza linie pod kątem wzorców błędów i zwraca te, które przekroczyły próg
+func (lm *logMatcher) scanErrors(lines []string, now time.Time) []errorMatch {
+	var matches []errorMatch
+	for _, line := range lines {
+		for _, rule := range lm.errors {
+			if rule.re.MatchString(line) && rule.observe(now) {
+				matches = append(matches, errorMatch{rule: rule, line: line})
+			}
+		}
+	}
+	return matches
+}
+
+// Czyści historię dopasowań (np. po restarcie procesu)
+func (lm *logMatcher) reset() {
+	for _, rule := range lm.errors {
+		rule.hits = rule.hits[:0]
+	}
+}
+
+// Obsługuje wzorce błędów w nowych liniach: alerty są wypisywane od razu,
+// a pierwszy wzorzec z akcją restartu jest zapamiętywany dla pętli nadzoru
+func (m *Monitor) handleErrorPatterns(lines []string) {
+	for _, match := range m.matcher.scanErrors(lines, time.Now()) {
+		if match.rule.cfg.Action == errorActionAlert {
+			m.logf("ALERT! %s\n", match.reason())
+			continue
+		}
+		if m.errorReason == "" {
+			m.errorReason = match.reason()
+		}
+	}
+}
+
+// Zwraca i czyści powód restartu wyzwolonego wzorcem błędu
+func (m *Monitor) takeErrorTrigger() string {
+	reason := m.errorReason
+	m.errorReason = ""
+	return reason
+}
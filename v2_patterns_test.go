@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestErrorRuleWindow(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := 10 * time.Second
+
+	tests := []struct {
+		name      string
+		threshold int
+		hits      []time.Duration // Chwile dopasowań względem t0
+		want      []bool          // Wynik observe dla kolejnych dopasowań
+	}{
+		{
+			name:      "próg 1",
+			threshold: 1,
+			hits:      []time.Duration{0, time.Second},
+			want:      []bool{true, true},
+		},
+		{
+			name:      "próg osiągnięty w oknie",
+			threshold: 3,
+			hits:      []time.Duration{0, 2 * time.Second, 4 * time.Second},
+			want:      []bool{false, false, true},
+		},
+		{
+			name:      "tuż przed krawędzią okna",
+			threshold: 2,
+			hits:      []time.Duration{0, window - time.Nanosecond},
+			want:      []bool{false, true},
+		},
+		{
+			name:      "dokładnie na krawędzi okna",
+			threshold: 2,
+			hits:      []time.Duration{0, window},
+			want:      []bool{false, false},
+		},
+		{
+			name:      "stare dopasowania wypadają z okna",
+			threshold: 3,
+			hits:      []time.Duration{0, 5 * time.Second, 12 * time.Second, 14 * time.Second},
+			want:      []bool{false, false, false, true},
+		},
+		{
+			name:      "po osiągnięciu progu liczenie od nowa",
+			threshold: 2,
+			hits:      []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second},
+			want:      []bool{false, true, false, true},
+		},
+		{
+			name:      "kilka dopasowań w tej samej chwili",
+			threshold: 3,
+			hits:      []time.Duration{0, 0, 0},
+			want:      []bool{false, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &errorRule{cfg: ErrorPatternConfig{Threshold: tt.threshold, Window: Duration{window}}}
+			for i, at := range tt.hits {
+				if got := rule.observe(t0.Add(at)); got != tt.want[i] {
+					t.Errorf("dopasowanie %d (po %v): observe = %v, oczekiwano %v", i+1, at, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	lm, err := newLogMatcher(ProgramConfig{ErrorPatterns: []ErrorPatternConfig{
+		{Pattern: "OutOfMemoryError"},
+		{Pattern: `ERROR db`, Threshold: 2, Window: Duration{time.Minute}, Action: errorActionAlert},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	matches := lm.scanErrors([]string{"INFO start", "ERROR db timeout", "INFO ok"}, now)
+	if len(matches) != 0 {
+		t.Fatalf("pojedynczy błąd db poniżej progu: %v", matches)
+	}
+	matches = lm.scanErrors([]string{"ERROR db timeout", "java.lang.OutOfMemoryError"}, now.Add(time.Second))
+	if len(matches) != 2 {
+		t.Fatalf("oczekiwano 2 dopasowań, jest %d", len(matches))
+	}
+	if matches[0].line != "ERROR db timeout" || matches[0].rule.cfg.Action != errorActionAlert {
+		t.Errorf("pierwsze dopasowanie: %+v", matches[0])
+	}
+	if matches[1].rule.cfg.Action != errorActionRestart {
+		t.Errorf("domyślna akcja = %q", matches[1].rule.cfg.Action)
+	}
+
+	// Reset (np. po restarcie procesu) czyści historię
+	lm.scanErrors([]string{"ERROR db timeout"}, now.Add(2*time.Second))
+	lm.reset()
+	if matches := lm.scanErrors([]string{"ERROR db timeout"}, now.Add(3*time.Second)); len(matches) != 0 {
+		t.Errorf("po reset próg osiągnięty z historią sprzed restartu: %v", matches)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Rodzaje sond
+const (
+	probeKindLiveness  = "liveness"  // Niepowodzenie powoduje restart
+	probeKindReadiness = "readiness" // Niepowodzenie oznacza tylko brak gotowości
+	probeKindStartup   = "startup"   // Sukces kończy fazę startu programu
+)
+
+// Domyślne ustawienia sond
+const (
+	defaultProbePeriod    = 10 * time.Second
+	defaultProbeTimeout   = 1 * time.Second
+	defaultProbeThreshold = 3
+)
+
+// Maksymalna liczba bajtów odpowiedzi HTTP sprawdzanych wzorcem
+const maxProbeBodyBytes = 64 << 10
+
+// Sonda HTTP GET
+type HTTPProbeConfig struct {
+	URL            string `json:"url"`             // Adres sprawdzany metodą GET
+	ExpectedStatus int    `json:"expected_status"` // Oczekiwany status (0 = dowolny 200-399)
+	BodyPattern    string `json:"body_pattern"`    // Wyrażenie regularne, które musi pasować do treści
+}
+
+// Sonda TCP - udane połączenie oznacza sukces
+type TCPProbeConfig struct {
+	Address string `json:"address"` // Adres w postaci host:port
+}
+
+// Sonda uruchamiająca komendę
+type ExecProbeConfig struct {
+	Command          string `json:"command"`            // Komenda uruchamiana przez sh -c
+	ExpectedExitCode int    `json:"expected_exit_code"` // Oczekiwany kod wyjścia (domyślnie 0)
+}
+
+// Konfiguracja pojedynczej sondy
+type ProbeConfig struct {
+	Name             string           `json:"name"`              // Nazwa sondy (w powodzie restartu)
+	Kind             string           `json:"kind"`              // "liveness" (domyślnie), "readiness" lub "startup"
+	HTTP             *HTTPProbeConfig `json:"http"`              // Sonda HTTP
+	TCP              *TCPProbeConfig  `json:"tcp"`               // Sonda TCP
+	Exec             *ExecProbeConfig `json:"exec"`              // Sonda exec
+	Period           Duration         `json:"period"`            // Co ile sprawdzać (domyślnie 10s)
+	Timeout          Duration         `json:"timeout"`           // Limit czasu pojedynczego sprawdzenia (domyślnie 1s)
+	FailureThreshold int              `json:"failure_threshold"` // Liczba kolejnych niepowodzeń (domyślnie 3)
+	InitialDelay     Duration         `json:"initial_delay"`     // Opóźnienie pierwszego sprawdzenia po starcie
+}
+
+// Uzupełnia brakujące ustawienia sondy
+func (p *ProbeConfig) applyDefaults() {
+	if p.Kind == "" {
+		p.Kind = probeKindLiveness
+	}
+	if p.Period.Duration == 0 {
+		p.Period.Duration = defaultProbePeriod
+	}
+	if p.Timeout.Duration == 0 {
+		p.Timeout.Duration = defaultProbeTimeout
+	}
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = defaultProbeThreshold
+	}
+	if p.Name == "" {
+		switch {
+		case p.HTTP != nil:
+			p.Name = "http " + p.HTTP.URL
+		case p.TCP != nil:
+			p.Name = "tcp " + p.TCP.Address
+		case p.Exec != nil:
+			p.Name = "exec " + p.Exec.Command
+		}
+	}
+}
+
+// Sprawdza poprawność konfiguracji sondy
+func (p *ProbeConfig) validate() error {
+	switch p.Kind {
+	case probeKindLiveness, probeKindReadiness, probeKindStartup:
+	default:
+		return fmt.Errorf("nieznany rodzaj %q (dozwolone: %s, %s, %s)",
+			p.Kind, probeKindLiveness, probeKindReadiness, probeKindStartup)
+	}
+
+	count := 0
+	if p.HTTP != nil {
+		count++
+		if p.HTTP.URL == "" {
+			return fmt.Errorf("http: brak url")
+		}
+		if _, err := regexp.Compile(p.HTTP.BodyPattern); err != nil {
+			return fmt.Errorf("http: nieprawidłowy body_pattern: %v", err)
+		}
+	}
+	if p.TCP != nil {
+		count++
+		if p.TCP.Address == "" {
+			return fmt.Errorf("tcp: brak address")
+		}
+	}
+	if p.Exec != nil {
+		count++
+		if p.Exec.Command == "" {
+			return fmt.Errorf("exec: brak command")
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("wymagany dokładnie jeden z typów: http, tcp, exec")
+	}
+
+	if p.Period.Duration <= 0 || p.Timeout.Duration <= 0 || p.FailureThreshold < 1 || p.InitialDelay.Duration < 0 {
+		return fmt.Errorf("period, timeout i failure_threshold muszą być dodatnie")
+	}
+	return nil
+}
+
+// Wykonuje sondę dla jednego procesu i zapamiętuje jej stan
+type probeRunner struct {
+	cfg     ProbeConfig
+	monitor *Monitor
+	pid     int            // PID sprawdzanego procesu
+	body    *regexp.Regexp // Skompilowany body_pattern (nil gdy brak)
+
+	mu       sync.Mutex
+	failures int    // Kolejne niepowodzenia
+	ready    bool   // Czy ostatnio sonda się powiodła
+	lastErr  string // Ostatni błąd sondy
+	reason   string // Powód restartu czekający na pętlę nadzoru
+}
+
+// Tworzy wykonawcę sondy
+func newProbeRunner(m *Monitor, cfg ProbeConfig) *probeRunner {
+	r := &probeRunner{cfg: cfg, monitor: m}
+	if cfg.HTTP != nil && cfg.HTTP.BodyPattern != "" {
+		r.body = regexp.MustCompile(cfg.HTTP.BodyPattern)
+	}
+	return r
+}
+
+// Pętla sondy - działa do anulowania kontekstu (zatrzymania procesu).
+// Sonda czeka na otwarcie bramki (koniec fazy startu), a sonda startowa
+// kończy pracę po pierwszym sukcesie.
+func (r *probeRunner) run(ctx context.Context, gate <-chan struct{}) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-gate:
+	}
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(r.cfg.InitialDelay.Duration):
+	}
+
+	ticker := time.NewTicker(r.cfg.Period.Duration)
+	defer ticker.Stop()
+	for {
+		err := r.check(ctx)
+		if ctx.Err() != nil {
+			return // Proces zatrzymywany - wynik nie ma znaczenia
+		}
+		r.observe(err)
+		if err == nil && r.cfg.Kind == probeKindStartup {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Wykonuje pojedyncze sprawdzenie z limitem czasu
+func (r *probeRunner) check(parent context.Context) error {
+	ctx, cancel := context.WithTimeout(parent, r.cfg.Timeout.Duration)
+	defer cancel()
+
+	switch {
+	case r.cfg.HTTP != nil:
+		return r.checkHTTP(ctx)
+	case r.cfg.TCP != nil:
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", r.cfg.TCP.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		return r.checkExec(ctx)
+	}
+}
+
+// Sonda HTTP: status i opcjonalnie treść odpowiedzi
+func (r *probeRunner) checkHTTP(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.cfg.HTTP.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if want := r.cfg.HTTP.ExpectedStatus; want != 0 && resp.StatusCode != want {
+		return fmt.Errorf("status %d, oczekiwano %d", resp.StatusCode, want)
+	} else if want == 0 && (resp.StatusCode < 200 || resp.StatusCode >= 400) {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if r.body != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodyBytes))
+		if err != nil {
+			return err
+		}
+		if !r.body.Match(body) {
+			return fmt.Errorf("treść odpowiedzi nie pasuje do %q", r.cfg.HTTP.BodyPattern)
+		}
+	}
+	return nil
+}
+
+// Sonda exec: kod wyjścia komendy. Komenda działa we własnej grupie
+// procesów, więc po przekroczeniu limitu czasu nie zostają jej potomkowie.
+func (r *probeRunner) checkExec(ctx context.Context) error {
+	env, err := r.monitor.commandEnv()
+	if err != nil {
+		return err
+	}
+	cmd := groupCommand(ctx, "sh", "-c", r.cfg.Exec.Command)
+	cmd.Dir = r.monitor.workingDir
+	cmd.Env = env
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("przekroczono limit czasu %v", r.cfg.Timeout.Duration)
+	}
+
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		return err
+	}
+	if code != r.cfg.Exec.ExpectedExitCode {
+		return fmt.Errorf("kod wyjścia %d, oczekiwano %d", code, r.cfg.Exec.ExpectedExitCode)
+	}
+	return nil
+}
+
+// Aktualizuje stan sondy po sprawdzeniu
+func (r *probeRunner) observe(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.monitor
+	if err == nil {
+		if !r.ready {
+			m.logf("Sonda %s (%s): OK\n", r.cfg.Name, r.cfg.Kind)
+		}
+		r.failures = 0
+		r.ready = true
+		r.lastErr = ""
+		return
+	}
+
+	r.failures++
+	r.lastErr = err.Error()
+	if r.cfg.Kind == probeKindStartup {
+		// O nieudanym starcie decyduje limit czasu fazy startu
+		m.logf("Sonda startowa %s: jeszcze nie gotowa (%v)\n", r.cfg.Name, err)
+		return
+	}
+	m.logf("Sonda %s (%s) nie powiodła się (%d/%d): %v\n",
+		r.cfg.Name, r.cfg.Kind, r.failures, r.cfg.FailureThreshold, err)
+	if r.failures < r.cfg.FailureThreshold {
+		return
+	}
+	if r.failures == r.cfg.FailureThreshold {
+		m.emit(Event{
+			Type:   eventProbeFailed,
+			PID:    r.pid,
+			Probe:  r.cfg.Name,
+			Reason: fmt.Sprintf("%s: %d kolejnych niepowodzeń: %s", r.cfg.Kind, r.failures, r.lastErr),
+		})
+	}
+
+	if r.ready && r.cfg.Kind == probeKindReadiness {
+		m.logf("Program nie jest gotowy - sonda %s\n", r.cfg.Name)
+	}
+	r.ready = false
+	if r.cfg.Kind == probeKindLiveness && r.reason == "" {
+		r.reason = fmt.Sprintf("sonda %s nie powiodła się %d razy: %s", r.cfg.Name, r.failures, r.lastErr)
+	}
+}
+
+// Zwraca i czyści powód restartu wyzwolonego przez sondę
+func (r *probeRunner) takeReason() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reason := r.reason
+	r.reason = ""
+	return reason
+}
+
+// Czy sonda ostatnio się powiodła
+func (r *probeRunner) isReady() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready
+}
+
+// Zawsze otwarta bramka dla sond startowych
+var closedGate = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Uruchamia sondy dla nowego procesu (wywoływana z zablokowanym mutexem)
+func (m *Monitor) startProbesUnsafe() {
+	m.stopProbesUnsafe()
+	if len(m.probeCfgs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.probeStop = cancel
+	m.probes = make([]*probeRunner, 0, len(m.probeCfgs))
+	for _, cfg := range m.probeCfgs {
+		r := newProbeRunner(m, cfg)
+		r.pid = m.pidUnsafe()
+		m.probes = append(m.probes, r)
+
+		// Sondy startowe działają od razu, pozostałe po zakończeniu startu
+		gate := m.started
+		if cfg.Kind == probeKindStartup {
+			gate = closedGate
+		}
+		go r.run(ctx, gate)
+	}
+}
+
+// Zatrzymuje sondy bieżącego procesu (wywoływana z zablokowanym mutexem)
+func (m *Monitor) stopProbesUnsafe() {
+	if m.probeStop != nil {
+		m.probeStop()
+		m.probeStop = nil
+	}
+}
+
+// Zwraca powód restartu wyzwolonego przez sondę liveness (pusty gdy brak)
+func (m *Monitor) takeProbeFailure() string {
+	m.mutex.RLock()
+	probes := m.probes
+	m.mutex.RUnlock()
+
+	for _, r := range probes {
+		if reason := r.takeReason(); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// Czy program jest gotowy - wszystkie sondy readiness ostatnio się powiodły
+func (m *Monitor) Ready() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.process == nil || m.state == stateStarting || m.state == stateStopping {
+		return false
+	}
+	for _, r := range m.probes {
+		if r.cfg.Kind == probeKindReadiness && !r.isReady() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Czas oczekiwania na zniknięcie procesów po SIGKILL
+const treeKillWait = 2 * time.Second
+
+// Co ile sprawdzać /proc podczas oczekiwania na zakończenie drzewa procesów
+const treePollInterval = 100 * time.Millisecond
+
+// Typ identyfikatora P_PID dla waitid(2) - brak w pakiecie syscall
+const waitidPID = 1
+
+// Wpis o procesie odczytany z /proc/<pid>/stat
+type procEntry struct {
+	pid   int
+	ppid  int
+	pgid  int
+	state byte   // Stan procesu (R, S, Z, ...)
+	start uint64 // Czas startu w taktach zegara - odróżnia procesy o tym samym PID
+
+	cpuTicks uint64 // Czas CPU (użytkownik + system) w taktach zegara
+	threads  int    // Liczba wątków
+	rssPages int64  // Pamięć rezydentna w stronach
+}
+
+// Odczytuje /proc/<pid>/stat
+func readProc(pid int) (procEntry, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procEntry{}, err
+	}
+
+	// Nazwa procesu jest w nawiasach i może zawierać spacje - pola
+	// liczymy od ostatniego nawiasu zamykającego
+	text := string(data)
+	end := strings.LastIndexByte(text, ')')
+	if end < 0 {
+		return procEntry{}, fmt.Errorf("nieprawidłowy format /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(text[end+1:])
+	if len(fields) < 20 {
+		return procEntry{}, fmt.Errorf("nieprawidłowy format /proc/%d/stat", pid)
+	}
+
+	entry := procEntry{pid: pid, state: fields[0][0]}
+	entry.ppid, _ = strconv.Atoi(fields[1])
+	entry.pgid, _ = strconv.Atoi(fields[2])
+	entry.start, _ = strconv.ParseUint(fields[19], 10, 64)
+	if len(fields) > 21 {
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		entry.cpuTicks = utime + stime
+		entry.threads, _ = strconv.Atoi(fields[17])
+		entry.rssPages, _ = strconv.ParseInt(fields[21], 10, 64)
+	}
+	return entry, nil
+}
+
+// Zwraca listę wszystkich procesów w systemie (pusta gdy brak /proc)
+func listProcs() []procEntry {
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	procs := make([]procEntry, 0, len(dirs))
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+		if entry, err := readProc(pid); err == nil {
+			procs = append(procs, entry)
+		}
+	}
+	return procs
+}
+
+// Zwraca drzewo procesu: członków jego grupy oraz wszystkich potomków
+// (także tych, którzy przeszli do innej grupy lub sesji)
+func processTree(root int) []procEntry {
+	procs := listProcs()
+	children := make(map[int][]procEntry)
+	for _, p := range procs {
+		children[p.ppid] = append(children[p.ppid], p)
+	}
+
+	seen := make(map[int]bool)
+	var tree []procEntry
+	add := func(p procEntry) {
+		if !seen[p.pid] {
+			seen[p.pid] = true
+			tree = append(tree, p)
+		}
+	}
+
+	for _, p := range procs {
+		if p.pgid == root {
+			add(p)
+		}
+	}
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			if !seen[child.pid] {
+				add(child)
+				queue = append(queue, child.pid)
+			}
+		}
+	}
+	return tree
+}
+
+// Czeka na zakończenie procesu bez zbierania go (WNOWAIT). Proces
+// pozostaje zombie, więc jego PID i numer grupy nie mogą jeszcze zostać
+// przydzielone innemu procesowi.
+func waitExited(pid int) error {
+	var info [128]byte // siginfo_t
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, waitidPID, uintptr(pid),
+			uintptr(unsafe.Pointer(&info[0])), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+			continue
+		default:
+			return errno
+		}
+	}
+}
+
+// Migawka potomków zakończonego, jeszcze niezebranego procesu (bez niego
+// samego). Pusta, gdy proces nie zostawił innych procesów.
+func orphanSnapshot(pid int) []procEntry {
+	if err := waitExited(pid); err != nil {
+		return nil
+	}
+	var orphans []procEntry
+	for _, p := range processTree(pid) {
+		if p.pid != pid {
+			orphans = append(orphans, p)
+		}
+	}
+	return orphans
+}
+
+// Zwraca procesy z drzewa, które nadal działają: z migawki (ten sam PID
+// i czas startu) oraz aktualnych członków grupy. Zombie są pomijane.
+// Nowych członków grupy szukamy tylko, gdy należy do niej któryś proces
+// z migawki - numer opustoszałej grupy mógł trafić do innego procesu.
+func treeSurvivors(pgid int, snapshot []procEntry) []procEntry {
+	var alive []procEntry
+	seen := make(map[int]bool)
+	grouped := false
+	for _, p := range snapshot {
+		if cur, err := readProc(p.pid); err == nil && cur.start == p.start && cur.state != 'Z' {
+			alive = append(alive, cur)
+			seen[cur.pid] = true
+			grouped = grouped || cur.pgid == pgid
+		}
+	}
+	if !grouped {
+		return alive
+	}
+	for _, p := range listProcs() {
+		if p.pgid == pgid && p.state != 'Z' && !seen[p.pid] {
+			alive = append(alive, p)
+		}
+	}
+	return alive
+}
+
+// Wysyła sygnał do całej grupy procesów oraz do potomków spoza grupy
+func signalTree(pgid int, procs []procEntry, sig syscall.Signal) error {
+	err := syscall.Kill(-pgid, sig)
+	for _, p := range procs {
+		if p.pgid != pgid {
+			syscall.Kill(p.pid, sig)
+		}
+	}
+	if err == syscall.ESRCH {
+		// Grupa już nie istnieje
+		return nil
+	}
+	return err
+}
+
+// Wysyła sygnał procesom, które przetrwały. Grupa jest sygnalizowana
+// tylko, gdy nadal należy do niej któryś z nich.
+func signalSurvivors(pgid int, alive []procEntry, sig syscall.Signal) {
+	for _, p := range alive {
+		if p.pgid == pgid {
+			signalTree(pgid, alive, sig)
+			return
+		}
+	}
+	for _, p := range alive {
+		syscall.Kill(p.pid, sig)
+	}
+}
+
+// Czeka aż wszystkie procesy drzewa się zakończą; zwraca pozostałe
+func waitTreeGone(pgid int, snapshot []procEntry, timeout time.Duration) []procEntry {
+	deadline := time.Now().Add(timeout)
+	for {
+		alive := treeSurvivors(pgid, snapshot)
+		if len(alive) == 0 || time.Now().After(deadline) {
+			return alive
+		}
+		time.Sleep(treePollInterval)
+	}
+}
+
+// Upewnia się, że po zakończeniu głównego procesu nie przetrwał żaden
+// potomek: pozostałym wysyła SIGTERM, po czasie grace SIGKILL.
+// Zwraca true gdy całe drzewo zostało zatrzymane.
+func (m *Monitor) ensureTreeStopped(pgid int, snapshot []procEntry, grace time.Duration) bool {
+	alive := treeSurvivors(pgid, snapshot)
+	if len(alive) == 0 {
+		return true
+	}
+
+	m.logf("Procesy potomne nadal działają (%s) - wysyłanie SIGTERM\n", formatPids(alive))
+	signalSurvivors(pgid, alive, syscall.SIGTERM)
+	if alive = waitTreeGone(pgid, alive, grace); len(alive) == 0 {
+		return true
+	}
+
+	m.logf("Wymuszanie zakończenia procesów potomnych (SIGKILL): %s\n", formatPids(alive))
+	signalSurvivors(pgid, alive, syscall.SIGKILL)
+	if alive = waitTreeGone(pgid, alive, treeKillWait); len(alive) == 0 {
+		return true
+	}
+
+	m.logf("UWAGA: procesy potomne przetrwały SIGKILL: %s\n", formatPids(alive))
+	return false
+}
+
+// Formatuje listę PID-ów do komunikatu
+func formatPids(procs []procEntry) string {
+	pids := make([]string, len(procs))
+	for i, p := range procs {
+		pids[i] = strconv.Itoa(p.pid)
+	}
+	return "PID " + strings.Join(pids, ", ")
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Kod wyjścia monitora po wykryciu pętli awarii z akcją "exit"
+const exitCodeCrashLoop = 3
+
+// Akcje po wykryciu pętli awarii
+const (
+	crashLoopActionStop = "stop" // Wstrzymanie restartów tylko tego programu
+	crashLoopActionExit = "exit" // Zakończenie całego monitora z kodem exitCodeCrashLoop
+)
+
+// Tryby polityki restartów (wzorowane na środowiskach kontenerowych)
+const (
+	restartPolicyAlways        = "always"         // Restart po każdym zakończeniu
+	restartPolicyOnFailure     = "on-failure"     // Restart tylko po niezerowym kodzie lub sygnale
+	restartPolicyNever         = "never"          // Bez restartów
+	restartPolicyUnlessStopped = "unless-stopped" // Jak always, ale zatrzymanie przetrwa przeładowanie konfiguracji
+)
+
+// Przyczyny restartów - stałe etykiety dla metryk i zdarzeń
+// (powód restartu to tekst ze szczegółami)
+const (
+	causeLogTimeout   = "log_timeout"    // Brak aktywności w logach
+	causeErrorPattern = "error_pattern"  // Wzorzec błędu w logach
+	causeProbe        = "probe_failed"   // Nieudana sonda liveness
+	causeStartup      = "startup_failed" // Nieudany start
+	causeExit         = "exited"         // Proces zakończył się
+	causeStartError   = "start_error"    // Nie udało się uruchomić procesu
+	causeManual       = "manual"         // Na żądanie operatora
+	causeOOM          = "oom_killed"     // Zabity przez OOM killer w cgroup programu
+	causeResource     = "resource_limit" // Przekroczony limit strażnika zasobów
+	causeMemoryLeak   = "memory_leak"    // Planowany restart przed wyczerpaniem pamięci
+)
+
+// Domyślne ustawienia polityki restartów
+const (
+	defaultBackoffInitial    = 1 * time.Second
+	defaultBackoffMax        = 60 * time.Second
+	defaultBackoffMultiplier = 2.0
+	defaultBackoffJitter     = 0.1
+	defaultRestartWindow     = 10 * time.Minute
+	defaultStableUptime      = 2 * time.Minute
+)
+
+// Polityka restartów: wykładnicze opóźnienie i wykrywanie pętli awarii
+type RestartConfig struct {
+	Policy            string   `json:"policy"`             // always (domyślnie), on-failure, never, unless-stopped
+	BackoffInitial    Duration `json:"backoff_initial"`    // Opóźnienie pierwszego restartu (domyślnie 1s)
+	BackoffMax        Duration `json:"backoff_max"`        // Maksymalne opóźnienie (domyślnie 60s)
+	BackoffMultiplier float64  `json:"backoff_multiplier"` // Mnożnik kolejnych opóźnień (domyślnie 2)
+	Jitter            float64  `json:"jitter"`             // Losowe odchylenie opóźnienia, np. 0.1 = ±10%
+	MaxRestarts       int      `json:"max_restarts"`       // Limit restartów w oknie (0 = bez limitu)
+	Window            Duration `json:"window"`             // Okno liczenia restartów (domyślnie 10m)
+	CrashLoopAction   string   `json:"crash_loop_action"`  // "stop" (domyślnie) lub "exit"
+	StableUptime      Duration `json:"stable_uptime"`      // Po takim czasie działania backoff jest zerowany (domyślnie 2m)
+}
+
+// Uzupełnia brakujące ustawienia polityki restartów
+func (r *RestartConfig) applyDefaults() {
+	if r.Policy == "" {
+		r.Policy = restartPolicyAlways
+	}
+	if r.BackoffInitial.Duration == 0 {
+		r.BackoffInitial.Duration = defaultBackoffInitial
+	}
+	if r.BackoffMax.Duration == 0 {
+		r.BackoffMax.Duration = defaultBackoffMax
+	}
+	if r.BackoffMultiplier == 0 {
+		r.BackoffMultiplier = defaultBackoffMultiplier
+	}
+	if r.Jitter == 0 {
+		r.Jitter = defaultBackoffJitter
+	}
+	if r.Window.Duration == 0 {
+		r.Window.Duration = defaultRestartWindow
+	}
+	if r.CrashLoopAction == "" {
+		r.CrashLoopAction = crashLoopActionStop
+	}
+	if r.StableUptime.Duration == 0 {
+		r.StableUptime.Duration = defaultStableUptime
+	}
+}
+
+// Sprawdza poprawność polityki restartów
+func (r *RestartConfig) validate() error {
+	switch r.Policy {
+	case restartPolicyAlways, restartPolicyOnFailure, restartPolicyNever, restartPolicyUnlessStopped:
+	default:
+		return fmt.Errorf("restart: nieznana polityka %q (dozwolone: %s, %s, %s, %s)", r.Policy,
+			restartPolicyAlways, restartPolicyOnFailure, restartPolicyNever, restartPolicyUnlessStopped)
+	}
+	if r.BackoffInitial.Duration < 0 || r.BackoffMax.Duration < r.BackoffInitial.Duration {
+		return fmt.Errorf("restart: backoff_max musi być nie mniejszy niż backoff_initial")
+	}
+	if r.BackoffMultiplier < 1 {
+		return fmt.Errorf("restart: backoff_multiplier musi być >= 1")
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return fmt.Errorf("restart: jitter musi być w zakresie 0-1")
+	}
+	if r.MaxRestarts < 0 {
+		return fmt.Errorf("restart: max_restarts nie może być ujemny")
+	}
+	if r.CrashLoopAction != crashLoopActionStop && r.CrashLoopAction != crashLoopActionExit {
+		return fmt.Errorf("restart: nieznana akcja crash_loop_action %q (dozwolone: %s, %s)",
+			r.CrashLoopAction, crashLoopActionStop, crashLoopActionExit)
+	}
+	return nil
+}
+
+// Czy po zakończeniu procesu należy go uruchomić ponownie. Brak wyniku
+// zakończenia (np. proces nie wystartował) traktowany jest jak awaria.
+func (r *RestartConfig) shouldRestart(exit *processExit) bool {
+	switch r.Policy {
+	case restartPolicyNever:
+		return false
+	case restartPolicyOnFailure:
+		return exit == nil || exit.failed()
+	default:
+		return true
+	}
+}
+
+// Oblicza opóźnienie kolejnego restartu na podstawie liczby
+// restartów od ostatniego stabilnego działania. Odchylenie losowe
+// nie przekracza backoff_max.
+func (r *RestartConfig) backoffDelay(failures int) time.Duration {
+	limit := float64(r.BackoffMax.Duration)
+	delay := math.Min(float64(r.BackoffInitial.Duration)*math.Pow(r.BackoffMultiplier, float64(failures)), limit)
+	if r.Jitter > 0 {
+		delay = math.Min(delay*(1+r.Jitter*(rand.Float64()*2-1)), limit)
+	}
+	return time.Duration(delay)
+}
+
+// Liczy restarty zapisane w historii w oknie polityki
+func (m *Monitor) restartsInWindow(now time.Time) int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	count := 0
+	cutoff := now.Add(-m.restartCfg.Window.Duration)
+	for _, r := range m.restarts {
+		if r.at.After(cutoff) {
+			count++
+		}
+	}
+	return count
+}
+
+// Zeruje backoff gdy proces działa nieprzerwanie dłużej niż stable_uptime
+func (m *Monitor) resetBackoffIfStable() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.failures > 0 && m.state == stateRunning &&
+		time.Since(m.startedAt) >= m.restartCfg.StableUptime.Duration {
+		m.logf("Proces działa stabilnie od %v - zerowanie opóźnienia restartów\n",
+			time.Since(m.startedAt).Round(time.Second))
+		m.failures = 0
+	}
+}
+
+// Obsługuje żądanie restartu: zapisuje je w historii, wykrywa pętlę awarii,
+// zatrzymuje proces i zwraca timer, po którym należy go uruchomić ponownie.
+// Zwraca nil, gdy restarty zostały wstrzymane.
+func (m *Monitor) requestRestart(cause, reason string) <-chan time.Time {
+	m.recordRestart(cause, reason)
+
+	if limit := m.restartCfg.MaxRestarts; limit > 0 {
+		if count := m.restartsInWindow(time.Now()); count > limit {
+			m.enterCrashLoop(count)
+			return nil
+		}
+	}
+
+	m.killProcess()
+
+	m.mutex.Lock()
+	delay := m.restartCfg.backoffDelay(m.failures)
+	m.failures++
+	attempt := m.failures
+	m.state = stateBackoff
+	m.mutex.Unlock()
+
+	if delay > 0 {
+		m.logf("Restart za %v (próba %d)\n", delay.Round(time.Millisecond), attempt)
+	}
+	return time.After(delay)
+}
+
+// Wykonuje zaplanowany restart działającego procesu. Nie jest awarią,
+// więc nie zwiększa opóźnienia kolejnych restartów. Zwraca timer
+// ponowienia, gdy nowy proces nie wystartował.
+func (m *Monitor) plannedRestart(cause, reason string) <-chan time.Time {
+	m.logf("Restartowanie procesu - powód: %s\n", reason)
+	m.recordRestart(cause, reason)
+	if err := m.Start(); err != nil {
+		m.logf("Błąd restartu: %v\n", err)
+		return m.requestRestart(causeStartError, fmt.Sprintf("błąd uruchomienia: %v", err))
+	}
+	m.logf("Proces zrestartowany pomyślnie\n")
+	return nil
+}
+
+// Przechodzi w stan pętli awarii - proces jest zatrzymywany i nie jest już restartowany
+func (m *Monitor) enterCrashLoop(count int) {
+	m.logf("PĘTLA AWARII! %d restartów w ciągu %v (limit: %d) - wstrzymuję restarty\n",
+		count, m.restartCfg.Window.Duration, m.restartCfg.MaxRestarts)
+	m.killProcess()
+
+	m.mutex.Lock()
+	m.state = stateCrashLoop
+	m.mutex.Unlock()
+
+	if m.onCrashLoop != nil {
+		m.onCrashLoop(m)
+	}
+}
+
+// Reaguje na zakończenie procesu zgodnie z polityką restartów.
+// Zwraca timer restartu lub nil, gdy proces nie będzie uruchamiany ponownie.
+func (m *Monitor) handleDeath(exit *processExit) <-chan time.Time {
+	if exit != nil {
+		m.logf("Proces PID %d zakończył się: %s\n", exit.pid, exit.summary())
+		if len(m.args) == 0 && exit.signal == 0 && (exit.exitCode == 126 || exit.exitCode == 127) {
+			m.logf("Kod %d: powłoka nie znalazła komendy lub nie mogła jej uruchomić - sprawdź command lub użyj args\n", exit.exitCode)
+		}
+		m.runCrashHook(causeExit, exit.describe(), exit, "")
+	}
+	if !m.restartCfg.shouldRestart(exit) {
+		m.markExited(exit)
+		return nil
+	}
+
+	// Bez wyniku zakończenia proces nie wystartował - przyczyną jest błąd uruchomienia
+	cause, reason := causeExit, "proces przestał działać"
+	if exit == nil {
+		if err := m.lastStartError(); err != nil {
+			cause, reason = causeStartError, fmt.Sprintf("błąd uruchomienia: %v", err)
+		}
+	}
+	if exit != nil && exit.oomKilled {
+		cause, reason = causeOOM, "proces zabity przez OOM killer"
+		if m.cgroupCfg.MemoryMax != "" {
+			reason += ", memory_max " + m.cgroupCfg.MemoryMax
+		}
+	}
+	if exit != nil {
+		reason += " (" + exit.describe() + ")"
+	}
+	m.logf("Restartowanie procesu - powód: %s\n", reason)
+	return m.requestRestart(cause, reason)
+}
+
+// Błąd ostatniej próby uruchomienia (nil gdy proces wystartował)
+func (m *Monitor) lastStartError() error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.startErr
+}
+
+// Oznacza program jako zakończony - polityka nie wymaga restartu
+func (m *Monitor) markExited(exit *processExit) {
+	if exit != nil {
+		m.logf("Proces zakończył się (%s) - polityka %s nie wymaga restartu\n",
+			exit.describe(), m.restartCfg.Policy)
+	}
+
+	m.mutex.Lock()
+	m.state = stateExited
+	m.mutex.Unlock()
+}
+
+// Czy program został zatrzymany na żądanie operatora
+func (m *Monitor) isUserStopped() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.userStopped
+}
+
+// Zatrzymuje program na żądanie operatora. Restarty są wstrzymane
+// niezależnie od polityki aż do wywołania Start().
+func (m *Monitor) Stop() {
+	m.mutex.Lock()
+	m.userStopped = true
+	m.mutex.Unlock()
+
+	m.killProcess()
+	m.logf("Program zatrzymany na żądanie\n")
+}
+
+// Uruchamia program zatrzymany na żądanie (lub zakończony) i wznawia nadzór
+func (m *Monitor) Start() error {
+	m.mutex.Lock()
+	m.userStopped = false
+	m.failures = 0
+	m.mutex.Unlock()
+
+	return m.startProcess()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	cfg := RestartConfig{
+		BackoffInitial:    Duration{time.Second},
+		BackoffMax:        Duration{60 * time.Second},
+		BackoffMultiplier: 2,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{4, 16 * time.Second},
+		{5, 32 * time.Second},
+		{6, 60 * time.Second}, // 64s - pierwsze obcięcie do backoff_max
+		{7, 60 * time.Second},
+		{1000, 60 * time.Second}, // Bez przepełnienia przy długiej serii awarii
+	}
+	for _, tt := range tests {
+		if got := cfg.backoffDelay(tt.failures); got != tt.want {
+			t.Errorf("backoffDelay(%d) = %v, oczekiwano %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffDelayMultiplierOne(t *testing.T) {
+	cfg := RestartConfig{
+		BackoffInitial:    Duration{5 * time.Second},
+		BackoffMax:        Duration{time.Minute},
+		BackoffMultiplier: 1,
+	}
+	for _, failures := range []int{0, 1, 10} {
+		if got := cfg.backoffDelay(failures); got != 5*time.Second {
+			t.Errorf("backoffDelay(%d) = %v, oczekiwano 5s", failures, got)
+		}
+	}
+}
+
+func TestBackoffDelayJitter(t *testing.T) {
+	cfg := RestartConfig{
+		BackoffInitial:    Duration{time.Second},
+		BackoffMax:        Duration{60 * time.Second},
+		BackoffMultiplier: 2,
+		Jitter:            0.2,
+	}
+
+	tests := []struct {
+		failures int
+		min, max time.Duration
+	}{
+		{0, 800 * time.Millisecond, 1200 * time.Millisecond},
+		{4, 12800 * time.Millisecond, 19200 * time.Millisecond},
+		{5, 25600 * time.Millisecond, 38400 * time.Millisecond},
+		// Przy limicie odchylenie tylko w dół - backoff_max nie jest przekraczany
+		{6, 48 * time.Second, 60 * time.Second},
+		{100, 48 * time.Second, 60 * time.Second},
+	}
+	for _, tt := range tests {
+		lo, hi := tt.max, tt.min
+		for i := 0; i < 1000; i++ {
+			got := cfg.backoffDelay(tt.failures)
+			if got < tt.min || got > tt.max {
+				t.Fatalf("backoffDelay(%d) = %v poza zakresem %v-%v", tt.failures, got, tt.min, tt.max)
+			}
+			if got < lo {
+				lo = got
+			}
+			if got > hi {
+				hi = got
+			}
+		}
+		// Odchylenie faktycznie występuje w obie strony
+		if mid := (tt.min + tt.max) / 2; tt.failures < 6 && (lo >= mid || hi <= mid) {
+			t.Errorf("backoffDelay(%d): wartości %v-%v nie są rozłożone wokół %v", tt.failures, lo, hi, mid)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Domyślny limit czasu startu, gdy podano warunek gotowości bez limitu
+const defaultStartupTimeout = 5 * time.Minute
+
+// Faza startu programu - do jej zakończenia nie działają restarty
+// z powodu ciszy w logach ani sondy liveness
+type StartupConfig struct {
+	Timeout    Duration `json:"timeout"`     // Limit czasu startu (bez warunku: okres karencji)
+	LogPattern string   `json:"log_pattern"` // Linia logu oznaczająca zakończenie startu
+}
+
+// Uzupełnia brakujące ustawienia fazy startu
+func (s *StartupConfig) applyDefaults(probes []ProbeConfig) {
+	if s.Timeout.Duration == 0 && (s.LogPattern != "" || hasProbeKind(probes, probeKindStartup)) {
+		s.Timeout.Duration = defaultStartupTimeout
+	}
+}
+
+// Sprawdza poprawność ustawień fazy startu
+func (s *StartupConfig) validate() error {
+	if s.Timeout.Duration < 0 {
+		return fmt.Errorf("startup: timeout nie może być ujemny")
+	}
+	if _, err := regexp.Compile(s.LogPattern); err != nil {
+		return fmt.Errorf("startup: nieprawidłowy log_pattern: %v", err)
+	}
+	return nil
+}
+
+// Czy lista sond zawiera sondę danego rodzaju
+func hasProbeKind(probes []ProbeConfig, kind string) bool {
+	for _, p := range probes {
+		if p.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Rozpoczyna fazę startu nowego procesu (wywoływana z zablokowanym mutexem)
+func (m *Monitor) beginStartupUnsafe() {
+	m.startupMatched = false
+	m.started = make(chan struct{})
+	if m.startupCfg.Timeout.Duration == 0 {
+		m.declareStartedUnsafe()
+		return
+	}
+	m.state = stateStarting
+	m.logf("Faza startu: limit %v\n", m.startupCfg.Timeout.Duration)
+}
+
+// Oznacza proces jako uruchomiony - od teraz liczy się cisza w logach
+// i działają sondy liveness oraz readiness
+func (m *Monitor) declareStartedUnsafe() {
+	select {
+	case <-m.started:
+		return
+	default:
+	}
+	close(m.started)
+	m.state = stateRunning
+	m.lastModTime = time.Now()
+}
+
+// Czy bieżący proces zakończył fazę startu
+func (m *Monitor) isStarted() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.started == nil {
+		return false
+	}
+	select {
+	case <-m.started:
+		return true
+	default:
+		return false
+	}
+}
+
+// Sprawdza nowe linie logu pod kątem wzorca zakończenia startu
+func (m *Monitor) observeStartupLines(lines []string) {
+	if m.startupPattern == nil || m.startupMatched {
+		return
+	}
+	for _, line := range lines {
+		if m.startupPattern.MatchString(line) {
+			m.startupMatched = true
+			return
+		}
+	}
+}
+
+// Sprawdza postęp fazy startu. Zwraca powód nieudanego startu
+// lub pusty tekst, gdy start trwa albo się zakończył.
+func (m *Monitor) checkStartup() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.process == nil || m.state != stateStarting {
+		return ""
+	}
+	elapsed := time.Since(m.startedAt)
+	limit := m.startupCfg.Timeout.Duration
+
+	// Bez warunku gotowości faza startu to tylko okres karencji
+	conditional := m.startupPattern != nil || hasProbeKind(m.probeCfgs, probeKindStartup)
+	if !conditional {
+		if elapsed >= limit {
+			m.logf("Koniec okresu karencji startu (%v)\n", limit)
+			m.declareStartedUnsafe()
+		}
+		return ""
+	}
+
+	var pending []string
+	if m.startupPattern != nil && !m.startupMatched {
+		pending = append(pending, fmt.Sprintf("linia pasująca do %q", m.startupCfg.LogPattern))
+	}
+	for _, r := range m.probes {
+		if r.cfg.Kind == probeKindStartup && !r.isReady() {
+			pending = append(pending, "sonda "+r.cfg.Name)
+		}
+	}
+
+	if len(pending) == 0 {
+		m.logf("Program uruchomiony po %v\n", elapsed.Round(time.Millisecond))
+		m.declareStartedUnsafe()
+		return ""
+	}
+	if elapsed < limit {
+		return ""
+	}
+
+	m.startupFailures++
+	m.logf("START NIEUDANY! Brak gotowości po %v (oczekiwano: %v)\n", limit, pending)
+	return fmt.Sprintf("nieudany start: brak gotowości w ciągu %v (%s)", limit, pending[0])
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Czas oczekiwania po SIGKILL w domyślnej sekwencji zatrzymania
+const defaultKillWait = 2 * time.Second
+
+// Domyślny limit czasu akcji przed zatrzymaniem
+const defaultPreStopTimeout = 10 * time.Second
+
+// Sygnały rozpoznawane po nazwie w konfiguracji
+var signalNames = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"TERM":  syscall.SIGTERM,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// Zamienia nazwę ("SIGTERM", "term") lub numer ("15") na sygnał
+func parseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	key := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if sig, ok := signalNames[key]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("nieznany sygnał %q", name)
+}
+
+// Zwraca nazwę sygnału w postaci SIGTERM (lub numer dla nieznanych)
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// Pojedynczy krok sekwencji zatrzymania
+type StopStep struct {
+	Signal  string   `json:"signal"`  // Sygnał wysyłany do grupy procesów
+	Timeout Duration `json:"timeout"` // Jak długo czekać na zakończenie po sygnale
+}
+
+// Akcja wykonywana przed wysłaniem pierwszego sygnału (np. drenaż ruchu)
+type PreStopConfig struct {
+	Command    string   `json:"command"`     // Komenda uruchamiana przez sh -c
+	HTTPURL    string   `json:"http_url"`    // Adres wywoływany przed zatrzymaniem
+	HTTPMethod string   `json:"http_method"` // Metoda HTTP (domyślnie POST)
+	Timeout    Duration `json:"timeout"`     // Limit czasu akcji (domyślnie 10s)
+}
+
+// Konfiguracja zatrzymywania procesu
+type StopConfig struct {
+	PreStop  *PreStopConfig `json:"pre_stop"` // Akcja przed zatrzymaniem (opcjonalna)
+	Sequence []StopStep     `json:"sequence"` // Kroki sygnał/czas; domyślnie SIGTERM (kill_grace), SIGKILL
+}
+
+// Uzupełnia brakujące ustawienia zatrzymywania
+func (s *StopConfig) applyDefaults() {
+	if s.PreStop != nil {
+		if s.PreStop.Timeout.Duration == 0 {
+			s.PreStop.Timeout.Duration = defaultPreStopTimeout
+		}
+		if s.PreStop.HTTPMethod == "" {
+			s.PreStop.HTTPMethod = http.MethodPost
+		}
+	}
+}
+
+// Sprawdza poprawność konfiguracji zatrzymywania
+func (s *StopConfig) validate() error {
+	for i, step := range s.Sequence {
+		if _, err := parseSignal(step.Signal); err != nil {
+			return fmt.Errorf("stop.sequence[%d]: %v", i, err)
+		}
+		if step.Timeout.Duration <= 0 {
+			return fmt.Errorf("stop.sequence[%d]: timeout musi być dodatni", i)
+		}
+	}
+	if p := s.PreStop; p != nil {
+		if p.Command == "" && p.HTTPURL == "" {
+			return fmt.Errorf("stop.pre_stop: wymagane command lub http_url")
+		}
+		if p.Timeout.Duration < 0 {
+			return fmt.Errorf("stop.pre_stop: timeout nie może być ujemny")
+		}
+	}
+	return nil
+}
+
+// Zwraca sekwencję zatrzymania; bez konfiguracji SIGTERM z kill_grace, potem SIGKILL
+func (s *StopConfig) steps(killGrace time.Duration) []StopStep {
+	if len(s.Sequence) > 0 {
+		return s.Sequence
+	}
+	return []StopStep{
+		{Signal: "SIGTERM", Timeout: Duration{killGrace}},
+		{Signal: "SIGKILL", Timeout: Duration{defaultKillWait}},
+	}
+}
+
+// Wynik ostatniego zatrzymania procesu
+type stopResult struct {
+	step     int            // Numer kroku (od 1), po którym proces się zakończył; 0 gdy nie zakończył się
+	signal   syscall.Signal // Sygnał tego kroku
+	duration time.Duration  // Czas od rozpoczęcia zatrzymania
+	killed   bool           // Czy był potrzebny SIGKILL
+}
+
+// Wykonuje akcję przed zatrzymaniem: komendę i/lub wywołanie HTTP
+func (m *Monitor) runPreStop(pid int) {
+	p := m.stopCfg.PreStop
+	if p == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout.Duration)
+	defer cancel()
+
+	if p.Command != "" {
+		m.logf("Akcja przed zatrzymaniem: %s\n", p.Command)
+		// Po przekroczeniu limitu zabijana jest cała grupa - potomek komendy
+		// trzymający otwarte wyjście nie przedłuża zatrzymania
+		env, err := m.commandEnv(
+			"MONITOR_PROGRAM="+m.name,
+			"MONITOR_PID="+strconv.Itoa(pid))
+		var out []byte
+		if err == nil {
+			cmd := groupCommand(ctx, "sh", "-c", p.Command)
+			cmd.Dir = m.workingDir
+			cmd.Env = env
+			out, err = cmd.CombinedOutput()
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			m.logf("Akcja przed zatrzymaniem przekroczyła limit czasu %v\n", p.Timeout.Duration)
+		} else if err != nil {
+			m.logf("Akcja przed zatrzymaniem nie powiodła się: %v %s\n", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if p.HTTPURL != "" {
+		m.logf("Akcja przed zatrzymaniem: %s %s\n", p.HTTPMethod, p.HTTPURL)
+		req, err := http.NewRequestWithContext(ctx, p.HTTPMethod, p.HTTPURL, nil)
+		if err != nil {
+			m.logf("Akcja przed zatrzymaniem nie powiodła się: %v\n", err)
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			m.logf("Akcja przed zatrzymaniem nie powiodła się: %v\n", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			m.logf("Akcja przed zatrzymaniem zwróciła status %s\n", resp.Status)
+		}
+	}
+}
+
+// Wykonuje kolejne kroki sekwencji zatrzymania aż proces się zakończy.
+// Sygnały trafiają do całej grupy procesów i do potomków z migawki drzewa.
+func (m *Monitor) runStopSequence(pid int, tree []procEntry, done <-chan struct{}) stopResult {
+	start := time.Now()
+	steps := m.stopCfg.steps(m.killGrace)
+
+	var result stopResult
+	for i, step := range steps {
+		sig, _ := parseSignal(step.Signal)
+		if i > 0 {
+			m.emit(Event{
+				Type:     eventStopEscalated,
+				PID:      pid,
+				Signal:   signalName(sig),
+				Reason:   fmt.Sprintf("proces nie zakończył się po kroku %d/%d (%s)", i, len(steps), steps[i-1].Signal),
+				Duration: time.Since(start).Seconds(),
+			})
+		}
+		m.logf("Krok %d/%d: wysyłanie %s, oczekiwanie do %v\n", i+1, len(steps), signalName(sig), step.Timeout.Duration)
+		if sig == syscall.SIGKILL {
+			result.killed = true
+		}
+		if err := signalTree(pid, tree, sig); err != nil {
+			m.logf("Błąd wysyłania %s: %v\n", signalName(sig), err)
+		}
+
+		select {
+		case <-done:
+			result.step = i + 1
+			result.signal = sig
+			result.duration = time.Since(start)
+			m.logf("Proces zakończony po kroku %d/%d (%s) po %v\n",
+				i+1, len(steps), signalName(sig), result.duration.Round(time.Millisecond))
+			return result
+		case <-time.After(step.Timeout.Duration):
+		}
+	}
+
+	result.duration = time.Since(start)
+	m.logf("Proces może nie zostać prawidłowo zamknięty - nie zakończył się po %d krokach\n", len(steps))
+	return result
+}
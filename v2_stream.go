@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Domyślna liczba ostatnich linii zwracanych przez polecenie logs
+const defaultLogLines = 10
+
+// Ile bajtów końca pliku czytać przy szukaniu ostatnich linii
+const maxLogTailBytes = 256 << 10
+
+// Jak często sprawdzać plik logów w trybie śledzenia (logs -f)
+const logFollowInterval = 250 * time.Millisecond
+
+// Zamknięty kanał po rozłączeniu klienta. Klient strumienia nic już nie
+// wysyła, więc każdy odczyt kończy się dopiero z końcem połączenia.
+func watchDisconnect(conn net.Conn) <-chan struct{} {
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+	return gone
+}
+
+// Przesyła zdarzenia wszystkich programów (lub jednego) do rozłączenia klienta
+func (c *controlServer) streamEvents(conn net.Conn, enc *json.Encoder, req controlRequest) {
+	events := c.supervisor.events.subscribe()
+	defer c.supervisor.events.unsubscribe(events)
+	gone := watchDisconnect(conn)
+
+	if enc.Encode(controlResponse{OK: true}) != nil {
+		return
+	}
+	for {
+		select {
+		case <-gone:
+			return
+		case e := <-events:
+			if req.Program != "" && e.Program != req.Program {
+				continue
+			}
+			if enc.Encode(controlResponse{OK: true, Event: &e}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// Przesyła ostatnie linie pliku logów programu, a w trybie śledzenia
+// także nowe linie (z obsługą rotacji i obcięcia pliku)
+func (c *controlServer) streamLogs(conn net.Conn, enc *json.Encoder, m *Monitor, req controlRequest) {
+	lines := req.Lines
+	if lines <= 0 {
+		lines = defaultLogLines
+	}
+
+	f, err := os.Open(m.logFile)
+	if err != nil {
+		enc.Encode(controlResponse{Error: err.Error()})
+		return
+	}
+	defer func() { f.Close() }()
+	if enc.Encode(controlResponse{OK: true}) != nil {
+		return
+	}
+
+	tail, offset, err := lastLines(f, lines)
+	if err != nil {
+		enc.Encode(controlResponse{Error: err.Error()})
+		return
+	}
+	for _, line := range tail {
+		if enc.Encode(controlResponse{OK: true, Line: line}) != nil {
+			return
+		}
+	}
+	if !req.Follow {
+		return
+	}
+
+	gone := watchDisconnect(conn)
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	buf := make([]byte, 64<<10)
+	partial := ""
+	for {
+		select {
+		case <-gone:
+			return
+		case <-ticker.C:
+		}
+
+		// Rotacja (nowy plik pod tą samą ścieżką) lub obcięcie - czytamy od początku
+		if info, err := os.Stat(m.logFile); err == nil {
+			cur, _ := f.Stat()
+			if cur == nil || fileIdentity(info) != fileIdentity(cur) || info.Size() < offset {
+				if nf, err := os.Open(m.logFile); err == nil {
+					f.Close()
+					f, offset, partial = nf, 0, ""
+				}
+			}
+		}
+
+		for {
+			n, err := f.ReadAt(buf, offset)
+			offset += int64(n)
+
+			parts := strings.Split(partial+string(buf[:n]), "\n")
+			partial = parts[len(parts)-1]
+			for _, line := range parts[:len(parts)-1] {
+				if enc.Encode(controlResponse{OK: true, Line: line}) != nil {
+					return
+				}
+			}
+			if err == io.EOF || n < len(buf) {
+				break
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Zwraca ostatnie n pełnych linii pliku i pozycję za ostatnim znakiem
+// nowej linii. Niedokończona linia na końcu pliku (bez \n) jest pomijana -
+// tryb śledzenia wypisze ją od tej pozycji, gdy zostanie dopisana do końca.
+func lastLines(f *os.File, n int) ([]string, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+	from := size - maxLogTailBytes
+	if from < 0 {
+		from = 0
+	}
+
+	data := make([]byte, size-from)
+	if _, err := f.ReadAt(data, from); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	end := strings.LastIndexByte(string(data), '\n')
+	if end < 0 {
+		return nil, from, nil
+	}
+	lines := strings.Split(string(data[:end]), "\n")
+	if from > 0 {
+		lines = lines[1:] // Pierwsza linia może być ucięta
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, from + int64(end) + 1, nil
+}
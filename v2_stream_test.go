@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLastLines(t *testing.T) {
+	long := strings.Repeat("x", maxLogTailBytes)
+
+	tests := []struct {
+		name   string
+		text   string
+		n      int
+		want   []string
+		offset int64
+	}{
+		{name: "pusty plik", text: "", n: 10, offset: 0},
+		{name: "pełne linie", text: "a\nb\nc\n", n: 10, want: []string{"a", "b", "c"}, offset: 6},
+		{name: "ostatnie n", text: "a\nb\nc\n", n: 2, want: []string{"b", "c"}, offset: 6},
+		{name: "pusta linia", text: "a\n\n", n: 10, want: []string{"a", ""}, offset: 3},
+		{name: "niedokończona linia", text: "a\nb\nc", n: 10, want: []string{"a", "b"}, offset: 4},
+		{name: "sama niedokończona linia", text: "abc", n: 10, offset: 0},
+		{name: "ucięta pierwsza linia", text: long + "\na\nb", n: 10, want: []string{"a"}, offset: int64(len(long)) + 3},
+		{name: "linia dłuższa niż odczyt", text: "a\n" + long, n: 10, offset: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			if err := os.WriteFile(path, []byte(tt.text), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			lines, offset, err := lastLines(f, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(lines, "|") != strings.Join(tt.want, "|") || len(lines) != len(tt.want) {
+				t.Errorf("linie = %q, oczekiwano %q", lines, tt.want)
+			}
+			if offset != tt.offset {
+				t.Errorf("pozycja = %d, oczekiwano %d", offset, tt.offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Supervisor nadzoruje wiele programów - każdy ma własny monitor i pętlę
+type Supervisor struct {
+	monitors []*Monitor                      // Monitory nadzorowanych programów
+	watchers map[*Monitor]context.CancelFunc // Zatrzymują pętle poszczególnych monitorów
+	wg       sync.WaitGroup                  // Czeka na zakończenie pętli monitorów
+	exitCode int                             // Kod wyjścia zwracany przez Run
+	mutex    sync.Mutex                      // Chroni exitCode, monitors i watchers
+	control  ControlConfig                   // Ustawienia gniazda sterującego
+	metrics  MetricsConfig                   // Ustawienia endpointu metryk
+	eventCfg EventsConfig                    // Dziennik zdarzeń i wyjście tekstowe
+	notify   []NotifierConfig                // Kanały powiadomień
+	loader   func() (*Config, error)         // Wczytuje konfigurację przy przeładowaniu
+	reloadMu sync.Mutex                      // Wyklucza równoległe przeładowania
+	events   *eventBus                       // Zdarzenia wszystkich programów
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// Konstruktor - tworzy supervisora dla listy programów
+func NewSupervisor(programs []ProgramConfig) (*Supervisor, error) {
+	monitors, err := newMonitors(programs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Supervisor{
+		monitors: monitors,
+		watchers: make(map[*Monitor]context.CancelFunc),
+		control:  ControlConfig{Disabled: true},
+		events:   newEventBus(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for _, m := range monitors {
+		s.attach(m)
+	}
+	return s, nil
+}
+
+// Tworzy monitory dla listy programów, sprawdzając ich konfigurację
+func newMonitors(programs []ProgramConfig) ([]*Monitor, error) {
+	if len(programs) == 0 {
+		return nil, fmt.Errorf("brak programów do nadzorowania")
+	}
+
+	names := make(map[string]bool)
+	monitors := make([]*Monitor, 0, len(programs))
+	for i, p := range programs {
+		p.applyDefaults()
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("program #%d (%s): %v", i+1, p.Name, err)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("zduplikowana nazwa programu: %s", p.Name)
+		}
+		names[p.Name] = true
+		monitors = append(monitors, NewMonitor(p))
+	}
+	return monitors, nil
+}
+
+// Podłącza monitor do supervisora (pętla awarii, zdarzenia)
+func (s *Supervisor) attach(m *Monitor) {
+	m.onCrashLoop = s.handleCrashLoop
+	m.events = s.events
+}
+
+// Ustawia gniazdo sterujące otwierane przez Run
+func (s *Supervisor) SetControl(cfg ControlConfig) {
+	s.control = cfg
+}
+
+// Ustawia endpoint metryk otwierany przez Run (pusty adres = wyłączony)
+func (s *Supervisor) SetMetrics(cfg MetricsConfig) {
+	cfg.applyDefaults()
+	s.metrics = cfg
+}
+
+// Ustawia dziennik zdarzeń JSON lines i wyjście tekstowe używane przez Run
+func (s *Supervisor) SetEvents(cfg EventsConfig) {
+	s.eventCfg = cfg
+}
+
+// Ustawia kanały powiadomień uruchamiane przez Run
+func (s *Supervisor) SetNotifiers(cfgs []NotifierConfig) {
+	s.notify = cfgs
+}
+
+// Ustawia funkcję wczytującą konfigurację - bez niej przeładowanie jest niedostępne
+func (s *Supervisor) SetLoader(loader func() (*Config, error)) {
+	s.loader = loader
+}
+
+// Reaguje na pętlę awarii programu - przy akcji "exit" kończy cały monitor
+func (s *Supervisor) handleCrashLoop(m *Monitor) {
+	if m.restartCfg.CrashLoopAction != crashLoopActionExit {
+		return
+	}
+
+	fmt.Fprintf(humanOutput, "[%s] Pętla awarii - zamykanie monitora (kod wyjścia %d)\n", m.name, exitCodeCrashLoop)
+	s.mutex.Lock()
+	s.exitCode = exitCodeCrashLoop
+	s.mutex.Unlock()
+	s.cancel()
+}
+
+// Zwraca monitor programu o podanej nazwie (nil jeśli nie istnieje)
+func (s *Supervisor) Monitor(name string) *Monitor {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, m := range s.monitors {
+		if m.name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Zwraca stan wszystkich programów lub tylko programu o podanej nazwie
+func (s *Supervisor) Status(name string) ([]ProgramStatus, error) {
+	s.mutex.Lock()
+	monitors := append([]*Monitor(nil), s.monitors...)
+	s.mutex.Unlock()
+
+	var result []ProgramStatus
+	for _, m := range monitors {
+		if name == "" || m.name == name {
+			result = append(result, m.Status())
+		}
+	}
+	if name != "" && len(result) == 0 {
+		return nil, fmt.Errorf("nieznany program %q", name)
+	}
+	return result, nil
+}
+
+// Uruchamia pętlę nadzoru monitora (i opcjonalnie jego proces)
+func (s *Supervisor) launch(m *Monitor, start bool) {
+	if start {
+		if err := m.startProcess(); err != nil {
+			// Pętla nadzoru spróbuje ponownie przy kolejnym sprawdzeniu
+			m.logf("Błąd uruchamiania: %v\n", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mutex.Lock()
+	s.watchers[m] = cancel
+	s.mutex.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		m.watch(ctx)
+	}()
+}
+
+// Zatrzymuje pętlę nadzoru monitora (wraz z procesem) i czeka na jej koniec
+func (s *Supervisor) retire(m *Monitor) {
+	s.mutex.Lock()
+	cancel := s.watchers[m]
+	delete(s.watchers, m)
+	s.mutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-m.stopped
+	}
+}
+
+// Przeładowuje konfigurację: nowe programy są uruchamiane, usunięte
+// zatrzymywane, a zmienione uruchamiane ponownie z nowymi ustawieniami.
+// Niezmienione programy działają dalej bez przerwy. Zmiany sekcji control,
+// metrics, events i notifiers wymagają restartu monitora - są tylko
+// zgłaszane w zwracanym podsumowaniu.
+func (s *Supervisor) Reload() (string, error) {
+	if s.loader == nil {
+		return "", fmt.Errorf("przeładowanie wymaga pliku konfiguracyjnego (--config)")
+	}
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	cfg, err := s.loader()
+	if err != nil {
+		return "", err
+	}
+	fresh, err := newMonitors(cfg.Programs)
+	if err != nil {
+		return "", err
+	}
+
+	s.mutex.Lock()
+	current := make(map[string]*Monitor, len(s.monitors))
+	for _, m := range s.monitors {
+		current[m.name] = m
+	}
+	s.mutex.Unlock()
+
+	var added, changed, removed []string
+	var kept, started []*Monitor
+	keep := make(map[string]bool)
+	for _, m := range fresh {
+		old := current[m.name]
+		switch {
+		case old == nil:
+			added = append(added, m.name)
+			started = append(started, m)
+		case reflect.DeepEqual(old.config, m.config):
+			// Niezmieniony program działa dalej - nowy monitor nie będzie używany
+			keep[m.name] = true
+			kept = append(kept, old)
+		default:
+			changed = append(changed, m.name)
+			// Zatrzymany na żądanie program z polityką unless-stopped
+			// pozostaje zatrzymany także po zmianie konfiguracji
+			if old.isUserStopped() && m.restartCfg.Policy == restartPolicyUnlessStopped {
+				m.userStopped = true
+			}
+			started = append(started, m)
+		}
+	}
+
+	// Pliki logów są otwierane dopiero po zatrzymaniu starych monitorów,
+	// które mogą pisać do tych samych plików
+	for _, m := range started {
+		if err := m.prepareLog(); err != nil {
+			return "", fmt.Errorf("program %s: %v", m.name, err)
+		}
+	}
+
+	for name, old := range current {
+		if !keep[name] {
+			if !containsName(fresh, name) {
+				removed = append(removed, name)
+			}
+			s.retire(old)
+		}
+	}
+
+	// Nowa lista zachowuje kolejność z pliku konfiguracyjnego
+	byName := make(map[string]*Monitor)
+	for _, m := range append(kept, started...) {
+		byName[m.name] = m
+	}
+	monitors := make([]*Monitor, 0, len(fresh))
+	for _, m := range fresh {
+		monitors = append(monitors, byName[m.name])
+	}
+	s.mutex.Lock()
+	s.monitors = monitors
+	s.mutex.Unlock()
+
+	for _, m := range started {
+		s.attach(m)
+		if err := m.openFiles(); err != nil {
+			m.logf("Błąd otwarcia pliku logów po przeładowaniu: %v\n", err)
+			continue
+		}
+		s.launch(m, !m.userStopped)
+	}
+
+	// Polityka unless-stopped utrzymuje zatrzymanie na żądanie,
+	// pozostałe polityki uruchamiają program ponownie
+	for _, m := range kept {
+		if m.isUserStopped() && m.restartCfg.Policy != restartPolicyUnlessStopped {
+			if err := m.request(actionStart); err != nil {
+				m.logf("Błąd uruchamiania po przeładowaniu: %v\n", err)
+			}
+		}
+	}
+
+	sort.Strings(removed)
+	summary := fmt.Sprintf("dodane: %s, zmienione: %s, usunięte: %s",
+		nameList(added), nameList(changed), nameList(removed))
+	if sections := s.restartSections(cfg); len(sections) > 0 {
+		summary += fmt.Sprintf(", wymaga restartu: %s", nameList(sections))
+		fmt.Fprintf(humanOutput, "Zmiany w sekcjach %s wymagają restartu monitora - pominięte\n", nameList(sections))
+	}
+	fmt.Fprintf(humanOutput, "Konfiguracja przeładowana - %s\n", summary)
+	s.events.publish(Event{Time: time.Now(), Type: eventConfigReloaded, Reason: summary})
+	return summary, nil
+}
+
+// Sekcje konfiguracji, których zmiana wymaga restartu monitora -
+// gniazdo, endpoint metryk, dziennik zdarzeń i kanały powiadomień
+// są tworzone raz przy starcie
+func (s *Supervisor) restartSections(cfg *Config) []string {
+	var sections []string
+	if !reflect.DeepEqual(s.control, cfg.Control) {
+		sections = append(sections, "control")
+	}
+	metrics := cfg.Metrics
+	metrics.applyDefaults()
+	if !reflect.DeepEqual(s.metrics, metrics) {
+		sections = append(sections, "metrics")
+	}
+	if !reflect.DeepEqual(s.eventCfg, cfg.Events) {
+		sections = append(sections, "events")
+	}
+	if !reflect.DeepEqual(s.notify, cfg.Notifiers) {
+		sections = append(sections, "notifiers")
+	}
+	return sections
+}
+
+// Czy lista monitorów zawiera program o podanej nazwie
+func containsName(monitors []*Monitor, name string) bool {
+	for _, m := range monitors {
+		if m.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Lista nazw do komunikatu ("-" gdy pusta)
+func nameList(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ", ")
+}
+
+// Główna pętla supervisora - metoda blokująca, zwraca kod wyjścia
+func (s *Supervisor) Run() int {
+	// Komunikaty tekstowe są opcjonalne - dziennik zdarzeń może je zastąpić
+	if !s.eventCfg.humanOutput() {
+		humanOutput = io.Discard
+	}
+	if s.eventCfg.File != "" {
+		if err := s.events.openLog(s.eventCfg.File); err != nil {
+			log.Fatalf("Błąd dziennika zdarzeń: %v", err)
+		}
+		defer s.events.closeLog()
+	}
+
+	fmt.Fprintln(humanOutput, "Uruchamianie monitora procesów...")
+	for _, m := range s.monitors {
+		fmt.Fprintf(humanOutput, "[%s] Plik logów: %s, timeout: %v, interwał: %v\n",
+			m.name, m.logFile, m.timeout, m.interval)
+	}
+	fmt.Fprintln(humanOutput, "Aby zatrzymać monitor, naciśnij Ctrl+C")
+	fmt.Fprintln(humanOutput, "--------------------------------------------------")
+
+	// Walidacja parametrów wszystkich programów przed uruchomieniem
+	for _, m := range s.monitors {
+		if err := m.validate(); err != nil {
+			log.Fatalf("[%s] Błąd walidacji: %v", m.name, err)
+		}
+	}
+
+	// Gniazdo sterujące. Zajęte gniazdo domyślne (np. inny monitor tego
+	// samego użytkownika) nie przerywa pracy - jawnie podane tak.
+	if !s.control.Disabled {
+		server, err := listenControl(s, s.control)
+		switch {
+		case err == nil:
+			fmt.Fprintf(humanOutput, "Gniazdo sterujące: %s\n", server.path)
+			go server.serve()
+			defer server.close()
+		case s.control.Socket == defaultControlSocket():
+			fmt.Fprintf(humanOutput, "Gniazdo sterujące niedostępne: %v\n", err)
+		default:
+			log.Fatalf("Błąd gniazda sterującego: %v", err)
+		}
+	}
+
+	// Endpoint metryk Prometheus
+	if s.metrics.Listen != "" {
+		stop, err := s.startMetrics(s.metrics)
+		if err != nil {
+			log.Fatalf("Błąd endpointu metryk: %v", err)
+		}
+		defer stop()
+	}
+
+	// Powiadomienia - zatrzymywane po programach, żeby wysłać ich ostatnie zdarzenia
+	if len(s.notify) > 0 {
+		defer s.startNotifiers(s.notify)()
+	}
+
+	// Obsługa sygnałów systemowych (Ctrl+C, kill, SIGHUP = przeładowanie)
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	// Uruchom wszystkie programy i ich pętle nadzoru
+	for _, m := range s.monitors {
+		s.launch(m, true)
+	}
+
+	for running := true; running; {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				fmt.Fprintln(humanOutput, "Otrzymano SIGHUP, przeładowanie konfiguracji...")
+				if _, err := s.Reload(); err != nil {
+					fmt.Fprintf(humanOutput, "Błąd przeładowania konfiguracji: %v\n", err)
+				}
+				continue
+			}
+			// Otrzymano sygnał zamknięcia
+			fmt.Fprintf(humanOutput, "\nOtrzymano sygnał %v, zamykanie monitora...\n", sig)
+			running = false
+		case <-s.ctx.Done():
+			fmt.Fprintln(humanOutput, "Monitor zakończony przez kontekst")
+			running = false
+		}
+	}
+
+	// Zatrzymaj wszystkie pętle - każda zamyka swój proces równolegle
+	s.cancel()
+	s.wg.Wait()
+	fmt.Fprintln(humanOutput, "Monitor zakończony")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.exitCode
+}
+
+// Zatrzymuje supervisora z zewnątrz (np. z innej goroutine)
+func (s *Supervisor) Stop() {
+	s.cancel()
+}
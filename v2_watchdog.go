@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Takty zegara na sekundę (USER_HZ) - stała na Linuksie dla /proc/<pid>/stat
+const clockTicks = 100
+
+// Akcje strażnika zasobów
+const (
+	watchdogRestart = "restart" // Restart programu
+	watchdogAlert   = "alert"   // Tylko komunikat i zdarzenie
+)
+
+// Domyślne ustawienia strażnika zasobów
+const (
+	defaultRSSChecks      = 3
+	defaultCPUBusyPercent = 95
+	defaultCPUIdlePercent = 1
+)
+
+// Limity zasobów drzewa procesów sprawdzane co interwał na podstawie /proc
+type WatchdogConfig struct {
+	RSSMaxMB       int      `json:"rss_max_mb"`       // Limit RSS całego drzewa w MB (0 = bez kontroli)
+	RSSChecks      int      `json:"rss_checks"`       // Ile kolejnych sprawdzeń ponad limitem (domyślnie 3)
+	CPUBusy        Duration `json:"cpu_busy"`         // Jak długo CPU może być zajęte ponad próg (0 = bez kontroli)
+	CPUBusyPercent float64  `json:"cpu_busy_percent"` // Próg zajętości w % jednego rdzenia (domyślnie 95)
+	CPUIdle        Duration `json:"cpu_idle"`         // Jak długo CPU może być bezczynne przy ciszy w logach (0 = bez kontroli)
+	CPUIdlePercent float64  `json:"cpu_idle_percent"` // Próg bezczynności w % (domyślnie 1)
+	FDMax          int      `json:"fd_max"`           // Limit otwartych deskryptorów (0 = bez kontroli)
+	ThreadsMax     int      `json:"threads_max"`      // Limit wątków (0 = bez kontroli)
+	Action         string   `json:"action"`           // "restart" (domyślnie) lub "alert"
+}
+
+// Uzupełnia brakujące ustawienia strażnika
+func (w *WatchdogConfig) applyDefaults() {
+	if w.RSSChecks == 0 {
+		w.RSSChecks = defaultRSSChecks
+	}
+	if w.CPUBusyPercent == 0 {
+		w.CPUBusyPercent = defaultCPUBusyPercent
+	}
+	if w.CPUIdlePercent == 0 {
+		w.CPUIdlePercent = defaultCPUIdlePercent
+	}
+	if w.Action == "" {
+		w.Action = watchdogRestart
+	}
+}
+
+// Sprawdza poprawność ustawień strażnika
+func (w *WatchdogConfig) validate() error {
+	if w.Action != watchdogRestart && w.Action != watchdogAlert {
+		return fmt.Errorf("watchdog: nieznana akcja %q (dozwolone: %s, %s)", w.Action, watchdogRestart, watchdogAlert)
+	}
+	if w.RSSMaxMB < 0 || w.RSSChecks < 1 || w.FDMax < 0 || w.ThreadsMax < 0 ||
+		w.CPUBusy.Duration < 0 || w.CPUIdle.Duration < 0 || w.CPUBusyPercent <= 0 || w.CPUIdlePercent < 0 {
+		return fmt.Errorf("watchdog: limity nie mogą być ujemne")
+	}
+	return nil
+}
+
+// Czy włączono jakąkolwiek kontrolę
+func (w *WatchdogConfig) enabled() bool {
+	return w.RSSMaxMB > 0 || w.CPUBusy.Duration > 0 || w.CPUIdle.Duration > 0 || w.FDMax > 0 || w.ThreadsMax > 0
+}
+
+// Zużycie zasobów drzewa procesów w chwili próbkowania
+type resourceUsage struct {
+	at         time.Time
+	pid        int     // Główny proces drzewa
+	procs      int     // Liczba procesów w drzewie
+	rssBytes   int64   // Suma pamięci rezydentnej
+	cpuPercent float64 // Zużycie CPU od poprzedniej próbki (100 = jeden rdzeń)
+	cpuValid   bool    // Czy była poprzednia próbka do porównania
+	fds        int     // Suma otwartych deskryptorów
+	threads    int     // Suma wątków
+}
+
+// Proces w poprzedniej próbce - PID i czas startu odróżniają procesy
+type procKey struct {
+	pid   int
+	start uint64
+}
+
+// Stan strażnika zasobów jednego programu. Używany tylko w pętli nadzoru.
+type resourceWatchdog struct {
+	lastAt    time.Time
+	lastTicks map[procKey]uint64 // Czas CPU procesów z poprzedniej próbki
+	rssOver   int                // Kolejne sprawdzenia z RSS ponad limitem
+	busySince time.Time          // Od kiedy CPU jest zajęte ponad próg
+	idleSince time.Time          // Od kiedy CPU jest bezczynne
+	alerted   bool               // Czy zgłoszono już bieżące przekroczenie
+}
+
+// Zeruje stan strażnika - nowy proces zaczyna od czystych liczników
+func (w *resourceWatchdog) reset() {
+	*w = resourceWatchdog{}
+}
+
+// Próbkuje zużycie zasobów całego drzewa procesu
+func (w *resourceWatchdog) sample(pid int) resourceUsage {
+	now := time.Now()
+	usage := resourceUsage{at: now, pid: pid}
+	ticks := make(map[procKey]uint64)
+	var used uint64
+	for _, p := range processTree(pid) {
+		if p.state == 'Z' {
+			continue
+		}
+		usage.procs++
+		usage.rssBytes += p.rssPages * int64(os.Getpagesize())
+		usage.threads += p.threads
+		if fds, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", p.pid)); err == nil {
+			usage.fds += len(fds)
+		}
+
+		// Czas CPU od poprzedniej próbki; nowy proces liczy się od startu
+		key := procKey{p.pid, p.start}
+		ticks[key] = p.cpuTicks
+		if prev, ok := w.lastTicks[key]; ok {
+			if p.cpuTicks > prev {
+				used += p.cpuTicks - prev
+			}
+		} else if w.lastTicks != nil {
+			used += p.cpuTicks
+		}
+	}
+
+	if w.lastTicks != nil {
+		if elapsed := now.Sub(w.lastAt).Seconds(); elapsed > 0 {
+			usage.cpuPercent = float64(used) / clockTicks / elapsed * 100
+			usage.cpuValid = true
+		}
+	}
+	w.lastAt, w.lastTicks = now, ticks
+	return usage
+}
+
+// Próbkuje zasoby drzewa procesu, gdy włączono strażnika lub wykrywanie
+// wycieku. Zwraca nil, gdy nie ma czego oceniać - także w fazie startu,
+// która ma własny limit czasu (rozgrzewka nie jest przekroczeniem).
+func (m *Monitor) sampleResources() *resourceUsage {
+	if !m.watchdogCfg.enabled() && !m.leakCfg.enabled() {
+		return nil
+	}
+	m.mutex.RLock()
+	pid := m.pidUnsafe()
+	m.mutex.RUnlock()
+	if pid == 0 {
+		return nil
+	}
+
+	usage := m.watchdog.sample(pid)
+	m.mutex.Lock()
+	m.usage = usage
+	m.mutex.Unlock()
+
+	if !m.isStarted() {
+		return nil
+	}
+	return &usage
+}
+
+// Porównuje próbkę zasobów z limitami. Zwraca powód restartu,
+// a w trybie alert tylko zgłasza przekroczenie i zwraca pusty tekst.
+func (m *Monitor) checkResources(usage *resourceUsage) string {
+	cfg := &m.watchdogCfg
+	if usage == nil || !cfg.enabled() {
+		return ""
+	}
+	m.mutex.RLock()
+	silence := time.Since(m.lastModTime)
+	m.mutex.RUnlock()
+	w := &m.watchdog
+
+	var breaches []string
+	if cfg.RSSMaxMB > 0 {
+		if usage.rssBytes > int64(cfg.RSSMaxMB)<<20 {
+			w.rssOver++
+		} else {
+			w.rssOver = 0
+		}
+		if w.rssOver >= cfg.RSSChecks {
+			breaches = append(breaches, fmt.Sprintf("RSS %d MB > %d MB przez %d kolejnych sprawdzeń",
+				usage.rssBytes>>20, cfg.RSSMaxMB, w.rssOver))
+		}
+	}
+	if cfg.CPUBusy.Duration > 0 && usage.cpuValid {
+		if usage.cpuPercent < cfg.CPUBusyPercent {
+			w.busySince = time.Time{}
+		} else if w.busySince.IsZero() {
+			w.busySince = usage.at
+		}
+		if busy := usage.at.Sub(w.busySince); !w.busySince.IsZero() && busy >= cfg.CPUBusy.Duration {
+			breaches = append(breaches, fmt.Sprintf("CPU %.0f%% (próg %.0f%%) przez %v - możliwa pętla",
+				usage.cpuPercent, cfg.CPUBusyPercent, busy.Round(time.Second)))
+		}
+	}
+	if cfg.CPUIdle.Duration > 0 && usage.cpuValid {
+		if usage.cpuPercent > cfg.CPUIdlePercent {
+			w.idleSince = time.Time{}
+		} else if w.idleSince.IsZero() {
+			w.idleSince = usage.at
+		}
+		idle := usage.at.Sub(w.idleSince)
+		if !w.idleSince.IsZero() && idle >= cfg.CPUIdle.Duration && silence >= cfg.CPUIdle.Duration {
+			breaches = append(breaches, fmt.Sprintf("CPU %.1f%% przez %v przy ciszy w logach - możliwe zakleszczenie",
+				usage.cpuPercent, idle.Round(time.Second)))
+		}
+	}
+	if cfg.FDMax > 0 && usage.fds > cfg.FDMax {
+		breaches = append(breaches, fmt.Sprintf("otwarte deskryptory %d > %d", usage.fds, cfg.FDMax))
+	}
+	if cfg.ThreadsMax > 0 && usage.threads > cfg.ThreadsMax {
+		breaches = append(breaches, fmt.Sprintf("wątki %d > %d", usage.threads, cfg.ThreadsMax))
+	}
+
+	if len(breaches) == 0 {
+		w.alerted = false
+		return ""
+	}
+	reason := "przekroczenie zasobów: " + strings.Join(breaches, "; ")
+	if cfg.Action == watchdogRestart {
+		m.emit(Event{Type: eventResourceLimit, PID: usage.pid, Reason: reason})
+		return reason
+	}
+
+	// Alert tylko raz na przekroczenie - ponownie po powrocie do normy
+	if !w.alerted {
+		w.alerted = true
+		m.logf("UWAGA! %s\n", reason)
+		m.emit(Event{Type: eventResourceLimit, PID: usage.pid, Reason: reason})
+	}
+	return ""
+}